@@ -2,17 +2,25 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	AWS      AWSConfig
-	Email    EmailConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	JWT       JWTConfig
+	AWS       AWSConfig
+	Storage   StorageConfig
+	Video     VideoConfig
+	Scanner   ScannerConfig
+	Scheduler SchedulerConfig
+	Timeline  TimelineConfig
+	Ranking   RankingConfig
+	Trending  TrendingConfig
+	Email     EmailConfig
 }
 
 // ServerConfig holds server-specific configuration
@@ -49,6 +57,74 @@ type AWSConfig struct {
 	CdnURL          string
 }
 
+// StorageConfig holds file storage configuration
+type StorageConfig struct {
+	Driver       string // "s3" or "local"
+	LocalDir     string
+	LocalBaseURL string
+	GCInterval   time.Duration
+	OrphanMaxAge time.Duration
+	// Per-user limits; zero disables a limit
+	QuotaBytes       int64
+	DailyUploadLimit int
+	DailyUploadBytes int64
+	// RejectForeignProfileURLs only lets avatars and covers point at our own uploads
+	RejectForeignProfileURLs bool
+}
+
+// VideoConfig holds video upload and transcoding configuration
+type VideoConfig struct {
+	MaxSize      int64
+	ChunkSize    int64
+	FFmpegPath   string
+	FFprobePath  string
+	WorkDir      string
+	PollInterval time.Duration
+}
+
+// ScannerConfig holds malware scanning configuration
+type ScannerConfig struct {
+	Driver       string // "", "clamav" or "fake"
+	ClamdAddress string
+	Timeout      time.Duration
+}
+
+// SchedulerConfig holds scheduled post publishing configuration
+type SchedulerConfig struct {
+	Interval time.Duration
+}
+
+// TimelineConfig holds home timeline configuration. Posts by accounts with more than
+// PullThreshold followers are read at request time instead of being fanned out.
+type TimelineConfig struct {
+	FanoutInterval time.Duration
+	PullThreshold  int
+	BackfillSize   int
+	// LiveUpdateInterval is the shortest time between two new_post events to a user
+	LiveUpdateInterval time.Duration
+}
+
+// RankingConfig holds ranked feed configuration. Candidates are the newest posts of a
+// timeline within Window; affinity counts interactions within AffinityWindow.
+type RankingConfig struct {
+	RecencyWeight  float64
+	VelocityWeight float64
+	AffinityWeight float64
+	HalfLife       time.Duration
+	AuthorSpacing  int
+	Candidates     int
+	Window         time.Duration
+	AffinityWindow time.Duration
+}
+
+// TrendingConfig holds trending ranking configuration. Gravity sets how quickly older
+// engagement loses weight within a window.
+type TrendingConfig struct {
+	RefreshInterval time.Duration
+	Size            int
+	Gravity         float64
+}
+
 // EmailConfig holds email-specific configuration
 type EmailConfig struct {
 	SMTPHost     string
@@ -104,6 +180,54 @@ func New() *Config {
 			Endpoint:        getEnv("AWS_ENDPOINT", ""),
 			CdnURL:          getEnv("AWS_CDN_URL", ""),
 		},
+		Storage: StorageConfig{
+			Driver:                   getEnv("STORAGE_DRIVER", "s3"),
+			LocalDir:                 getEnv("STORAGE_LOCAL_DIR", "./data"),
+			LocalBaseURL:             getEnv("STORAGE_LOCAL_BASE_URL", "http://localhost:8080/files"),
+			GCInterval:               getDurationEnv("STORAGE_GC_INTERVAL", time.Hour),
+			OrphanMaxAge:             getDurationEnv("STORAGE_ORPHAN_MAX_AGE", 24*time.Hour),
+			QuotaBytes:               int64(getIntEnv("STORAGE_QUOTA_MB", 1024)) * 1024 * 1024,
+			DailyUploadLimit:         getIntEnv("UPLOAD_DAILY_LIMIT", 100),
+			DailyUploadBytes:         int64(getIntEnv("UPLOAD_DAILY_MB", 500)) * 1024 * 1024,
+			RejectForeignProfileURLs: getBoolEnv("REJECT_FOREIGN_PROFILE_URLS", false),
+		},
+		Video: VideoConfig{
+			MaxSize:      int64(getIntEnv("VIDEO_MAX_SIZE_MB", 100)) * 1024 * 1024,
+			ChunkSize:    int64(getIntEnv("VIDEO_CHUNK_SIZE_MB", 5)) * 1024 * 1024,
+			FFmpegPath:   getEnv("FFMPEG_PATH", "ffmpeg"),
+			FFprobePath:  getEnv("FFPROBE_PATH", "ffprobe"),
+			WorkDir:      getEnv("VIDEO_WORK_DIR", os.TempDir()),
+			PollInterval: getDurationEnv("VIDEO_POLL_INTERVAL", 10*time.Second),
+		},
+		Scanner: ScannerConfig{
+			Driver:       getEnv("SCANNER_DRIVER", ""),
+			ClamdAddress: getEnv("CLAMD_ADDRESS", "localhost:3310"),
+			Timeout:      getDurationEnv("SCANNER_TIMEOUT", 30*time.Second),
+		},
+		Scheduler: SchedulerConfig{
+			Interval: getDurationEnv("SCHEDULER_INTERVAL", 30*time.Second),
+		},
+		Timeline: TimelineConfig{
+			FanoutInterval:     getDurationEnv("TIMELINE_FANOUT_INTERVAL", 5*time.Second),
+			PullThreshold:      getIntEnv("TIMELINE_PULL_THRESHOLD", 10000),
+			BackfillSize:       getIntEnv("TIMELINE_BACKFILL_SIZE", 100),
+			LiveUpdateInterval: getDurationEnv("TIMELINE_LIVE_UPDATE_INTERVAL", 30*time.Second),
+		},
+		Ranking: RankingConfig{
+			RecencyWeight:  getFloatEnv("RANKING_RECENCY_WEIGHT", 1),
+			VelocityWeight: getFloatEnv("RANKING_VELOCITY_WEIGHT", 1),
+			AffinityWeight: getFloatEnv("RANKING_AFFINITY_WEIGHT", 0.5),
+			HalfLife:       getDurationEnv("RANKING_HALF_LIFE", 6*time.Hour),
+			AuthorSpacing:  getIntEnv("RANKING_AUTHOR_SPACING", 3),
+			Candidates:     getIntEnv("RANKING_CANDIDATES", 500),
+			Window:         getDurationEnv("RANKING_WINDOW", 72*time.Hour),
+			AffinityWindow: getDurationEnv("RANKING_AFFINITY_WINDOW", 30*24*time.Hour),
+		},
+		Trending: TrendingConfig{
+			RefreshInterval: getDurationEnv("TRENDING_REFRESH_INTERVAL", 5*time.Minute),
+			Size:            getIntEnv("TRENDING_SIZE", 200),
+			Gravity:         getFloatEnv("TRENDING_GRAVITY", 1.5),
+		},
 		Email: EmailConfig{
 			SMTPHost:     getEnv("EMAIL_SMTP_HOST", "smtp.example.com"),
 			SMTPPort:     getEnv("EMAIL_SMTP_PORT", "587"),
@@ -124,3 +248,39 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getDurationEnv gets a duration environment variable or returns a default value
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getIntEnv gets an integer environment variable or returns a default value
+func getIntEnv(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getFloatEnv gets a floating point environment variable or returns a default value
+func getFloatEnv(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(getEnv(key, ""), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getBoolEnv gets a boolean environment variable or returns a default value
+func getBoolEnv(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"socialnet/config"
+	"socialnet/middleware"
+	"socialnet/model"
+	"socialnet/repository"
+	"socialnet/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BookmarkController handles bookmark-related requests. Bookmarks are private to the
+// user who saved them.
+type BookmarkController struct {
+	repo *repository.Repository
+	cfg  *config.Config
+}
+
+// NewBookmarkController creates a new BookmarkController
+func NewBookmarkController(repo *repository.Repository, cfg *config.Config) *BookmarkController {
+	return &BookmarkController{
+		repo: repo,
+		cfg:  cfg,
+	}
+}
+
+// BookmarkPost saves a post for the current user, optionally in a collection. Saving
+// a bookmarked post again moves it to the given collection.
+func (bc *BookmarkController) BookmarkPost(c *gin.Context) {
+	postID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	var input model.BookmarkCreate
+	if c.Request.ContentLength > 0 && !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	// Check if post exists
+	if _, err := bc.repo.Post.FindByID(postID); err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	if input.CollectionID != nil {
+		if _, ok := bc.findOwnedCollection(c, *input.CollectionID, userID); !ok {
+			return
+		}
+	}
+
+	bookmark := model.Bookmark{
+		UserID:       userID,
+		PostID:       postID,
+		CollectionID: input.CollectionID,
+	}
+	if err := bc.repo.Bookmark.Save(&bookmark); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to bookmark post")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Post bookmarked successfully", bookmark)
+}
+
+// UnbookmarkPost removes a post from the current user's bookmarks
+func (bc *BookmarkController) UnbookmarkPost(c *gin.Context) {
+	postID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	removed, err := bc.repo.Bookmark.Delete(userID, postID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to remove bookmark")
+		return
+	}
+
+	if !removed {
+		util.RespondWithError(c, http.StatusNotFound, "Post not bookmarked")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Bookmark removed successfully", nil)
+}
+
+// GetBookmarks returns the current user's bookmarked posts, most recently saved first
+func (bc *BookmarkController) GetBookmarks(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	var filter model.BookmarkFilter
+	if !middleware.BindQuery(c, &filter) {
+		return
+	}
+
+	if filter.CollectionID != "" {
+		if _, ok := bc.findOwnedCollection(c, uuid.MustParse(filter.CollectionID), userID); !ok {
+			return
+		}
+	}
+
+	posts, meta, err := bc.repo.Bookmark.FindPosts(userID, filter)
+	if err != nil {
+		util.RespondWithListError(c, err, "Failed to fetch bookmarks")
+		return
+	}
+
+	if posts, err = fillViewerInfo(bc.repo, &userID, posts); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch like info")
+		return
+	}
+
+	util.RespondWithPagination(c, http.StatusOK, "Bookmarks retrieved successfully", posts, meta)
+}
+
+// GetCollections returns the current user's bookmark collections
+func (bc *BookmarkController) GetCollections(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	collections, err := bc.repo.Bookmark.FindCollections(userID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch collections")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Collections retrieved successfully", collections)
+}
+
+// CreateCollection adds a named bookmark collection for the current user
+func (bc *BookmarkController) CreateCollection(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	var input model.BookmarkCollectionInput
+	if !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	name, ok := collectionName(c, input.Name)
+	if !ok {
+		return
+	}
+
+	collection := model.BookmarkCollection{
+		UserID: userID,
+		Name:   name,
+	}
+	err := bc.repo.Bookmark.CreateCollection(&collection)
+	if errors.Is(err, repository.ErrCollectionExists) {
+		util.RespondWithError(c, http.StatusConflict, "A collection with this name already exists")
+		return
+	}
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to create collection")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusCreated, "Collection created successfully", collection)
+}
+
+// UpdateCollection renames one of the current user's bookmark collections
+func (bc *BookmarkController) UpdateCollection(c *gin.Context) {
+	collectionID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid collection ID format")
+		return
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	collection, ok := bc.findOwnedCollection(c, collectionID, userID)
+	if !ok {
+		return
+	}
+
+	var input model.BookmarkCollectionInput
+	if !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	name, ok := collectionName(c, input.Name)
+	if !ok {
+		return
+	}
+
+	err = bc.repo.Bookmark.RenameCollection(collection, name)
+	if errors.Is(err, repository.ErrCollectionExists) {
+		util.RespondWithError(c, http.StatusConflict, "A collection with this name already exists")
+		return
+	}
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to update collection")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Collection updated successfully", collection)
+}
+
+// DeleteCollection deletes one of the current user's bookmark collections. The posts it
+// held stay bookmarked.
+func (bc *BookmarkController) DeleteCollection(c *gin.Context) {
+	collectionID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid collection ID format")
+		return
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	if _, ok := bc.findOwnedCollection(c, collectionID, userID); !ok {
+		return
+	}
+
+	if err := bc.repo.Bookmark.DeleteCollection(collectionID); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to delete collection")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Collection deleted successfully", nil)
+}
+
+// findOwnedCollection loads a collection of the current user. Other users' collections
+// are reported as missing so that their existence isn't revealed.
+func (bc *BookmarkController) findOwnedCollection(c *gin.Context, id, userID uuid.UUID) (*model.BookmarkCollection, bool) {
+	collection, err := bc.repo.Bookmark.FindCollection(id)
+	if err != nil || collection.UserID != userID {
+		util.RespondWithError(c, http.StatusNotFound, "Collection not found")
+		return nil, false
+	}
+	return collection, true
+}
+
+// collectionName trims a collection name and rejects blank ones
+func collectionName(c *gin.Context, name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		util.RespondWithError(c, http.StatusBadRequest, "Collection name is required")
+		return "", false
+	}
+	return name, true
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"log"
 	"net/http"
 
 	"socialnet/config"
@@ -36,25 +37,40 @@ func (cc *CommentController) GetComments(c *gin.Context) {
 	}
 
 	// Check if post exists
-	_, err = cc.repo.Post.FindByID(postID)
+	post, err := cc.repo.Post.FindByID(postID)
 	if err != nil {
 		util.RespondWithError(c, http.StatusNotFound, "Post not found")
 		return
 	}
 
-	var filter model.Pagination
+	var filter model.CommentListFilter
 	if !middleware.BindQuery(c, &filter) {
 		return
 	}
 
+	currentUserID := middleware.GetOptionalUserID(c)
+
 	// Get comments for post
-	comments, err := cc.repo.Comment.FindByPostID(postID, filter)
+	comments, meta, err := cc.repo.Comment.FindByPostID(postID, currentUserID, filter)
 	if err != nil {
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch comments")
+		util.RespondWithListError(c, err, "Failed to fetch comments")
+		return
+	}
+
+	// The pinned comment leads the first page
+	if post.PinnedCommentID != nil && filter.Cursor == "" && filter.Offset == 0 {
+		if pinned, err := cc.repo.Comment.FindByID(*post.PinnedCommentID); err == nil {
+			pinned.Pinned = true
+			comments = append([]model.Comment{*pinned}, comments...)
+		}
+	}
+
+	if comments, err = cc.repo.Comment.FillLikeInfo(currentUserID, comments); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch like info")
 		return
 	}
 
-	util.RespondWithSuccess(c, http.StatusOK, "Comments retrieved successfully", comments)
+	util.RespondWithPagination(c, http.StatusOK, "Comments retrieved successfully", comments, meta)
 }
 
 // CreateComment adds a comment to a post
@@ -71,23 +87,38 @@ func (cc *CommentController) CreateComment(c *gin.Context) {
 	}
 
 	// Check if post exists
-	_, err = cc.repo.Post.FindByID(postID)
+	post, err := cc.repo.Post.FindByID(postID)
 	if err != nil {
 		util.RespondWithError(c, http.StatusNotFound, "Post not found")
 		return
 	}
 
+	if !cc.checkCommentPolicy(c, post, userID) {
+		return
+	}
+
 	var input model.CommentCreate
 	if !middleware.BindJSON(c, &input) {
 		return
 	}
 
+	// Replies must stay under the same post as the comment they answer
+	var parent *model.Comment
+	if input.ParentID != nil {
+		parent, err = cc.repo.Comment.FindVisibleByID(*input.ParentID, &userID)
+		if err != nil || parent.PostID != postID {
+			util.RespondWithError(c, http.StatusBadRequest, "Comment to reply to not found on this post")
+			return
+		}
+	}
+
 	// Create new comment
 	comment := model.Comment{
-		ID:      uuid.New(),
-		UserID:  userID,
-		PostID:  postID,
-		Content: input.Content,
+		ID:       uuid.New(),
+		UserID:   userID,
+		PostID:   postID,
+		ParentID: input.ParentID,
+		Content:  input.Content,
 	}
 
 	// Save comment to database
@@ -97,6 +128,12 @@ func (cc *CommentController) CreateComment(c *gin.Context) {
 		return
 	}
 
+	if parent != nil {
+		if err := cc.repo.Notification.CreateReplyNotification(userID, parent.UserID, comment.ID); err != nil {
+			log.Printf("Failed to notify author of comment %s about a reply: %v", parent.ID, err)
+		}
+	}
+
 	// Get the created comment with author details
 	createdComment, err := cc.repo.Comment.FindByID(comment.ID)
 	if err != nil {
@@ -109,6 +146,127 @@ func (cc *CommentController) CreateComment(c *gin.Context) {
 	util.RespondWithSuccess(c, http.StatusCreated, "Comment created successfully", createdComment)
 }
 
+// GetReplies returns the replies to a comment
+func (cc *CommentController) GetReplies(c *gin.Context) {
+	commentID, err := middleware.ParseUUIDParam(c, "commentId")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid comment ID format")
+		return
+	}
+
+	currentUserID := middleware.GetOptionalUserID(c)
+
+	// Check if comment exists and is visible to the viewer
+	_, err = cc.repo.Comment.FindVisibleByID(commentID, currentUserID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	var filter model.Pagination
+	if !middleware.BindQuery(c, &filter) {
+		return
+	}
+
+	replies, meta, err := cc.repo.Comment.FindReplies(commentID, currentUserID, filter)
+	if err != nil {
+		util.RespondWithListError(c, err, "Failed to fetch replies")
+		return
+	}
+
+	if replies, err = cc.repo.Comment.FillLikeInfo(currentUserID, replies); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch like info")
+		return
+	}
+
+	util.RespondWithPagination(c, http.StatusOK, "Replies retrieved successfully", replies, meta)
+}
+
+// LikeComment adds a like to a comment
+func (cc *CommentController) LikeComment(c *gin.Context) {
+	commentID, err := middleware.ParseUUIDParam(c, "commentId")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid comment ID format")
+		return
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	// Check if comment exists and is visible to the user
+	comment, err := cc.repo.Comment.FindVisibleByID(commentID, &userID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	// Check if already liked
+	isLiked, err := cc.repo.Comment.IsLiked(userID, commentID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, util.ErrorMessages.DatabaseError)
+		return
+	}
+
+	if isLiked {
+		util.RespondWithError(c, http.StatusConflict, "Comment already liked")
+		return
+	}
+
+	// Add like to database
+	likeCount, err := cc.repo.Comment.Like(userID, commentID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to like comment")
+		return
+	}
+
+	if err := cc.repo.Notification.CreateCommentLikeNotification(userID, comment.UserID, comment.ID); err != nil {
+		log.Printf("Failed to notify author of comment %s about a like: %v", comment.ID, err)
+	}
+
+	util.RespondWithSuccess(c, http.StatusCreated, "Comment liked successfully", gin.H{
+		"likes": likeCount,
+	})
+}
+
+// UnlikeComment removes a like from a comment
+func (cc *CommentController) UnlikeComment(c *gin.Context) {
+	commentID, err := middleware.ParseUUIDParam(c, "commentId")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid comment ID format")
+		return
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	// Check if like exists
+	isLiked, err := cc.repo.Comment.IsLiked(userID, commentID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, util.ErrorMessages.DatabaseError)
+		return
+	}
+
+	if !isLiked {
+		util.RespondWithError(c, http.StatusNotFound, "Comment not liked")
+		return
+	}
+
+	// Remove like from database
+	likeCount, err := cc.repo.Comment.Unlike(userID, commentID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to unlike comment")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Comment unliked successfully", gin.H{
+		"likes": likeCount,
+	})
+}
+
 // UpdateComment updates an existing comment
 func (cc *CommentController) UpdateComment(c *gin.Context) {
 	commentID, err := middleware.ParseUUIDParam(c, "commentId")
@@ -152,6 +310,36 @@ func (cc *CommentController) UpdateComment(c *gin.Context) {
 	util.RespondWithSuccess(c, http.StatusOK, "Comment updated successfully", comment)
 }
 
+// GetCommentRevisions returns the previous versions of a comment, newest first, each
+// with the changes the following edit made to it
+func (cc *CommentController) GetCommentRevisions(c *gin.Context) {
+	commentID, err := middleware.ParseUUIDParam(c, "commentId")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid comment ID format")
+		return
+	}
+
+	comment, err := cc.repo.Comment.FindVisibleByID(commentID, middleware.GetOptionalUserID(c))
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	revisions, err := cc.repo.Comment.FindRevisions(comment.ID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch revisions")
+		return
+	}
+
+	next := comment.Content
+	for i := range revisions {
+		revisions[i].Diff = util.WordDiff(revisions[i].Content, next)
+		next = revisions[i].Content
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Revisions retrieved successfully", revisions)
+}
+
 // DeleteComment deletes a comment
 func (cc *CommentController) DeleteComment(c *gin.Context) {
 	commentID, err := middleware.ParseUUIDParam(c, "commentId")
@@ -172,9 +360,13 @@ func (cc *CommentController) DeleteComment(c *gin.Context) {
 		return
 	}
 
-	// Check if user owns the comment
-	if !middleware.CheckResourceOwnership(c, comment.UserID, userID) {
-		return
+	// Comments can be deleted by their author and by the owner of the post
+	if comment.UserID != userID {
+		post, err := cc.repo.Post.FindByID(comment.PostID)
+		if err != nil || post.UserID != userID {
+			util.RespondWithError(c, http.StatusForbidden, "You don't have permission to delete this comment")
+			return
+		}
 	}
 
 	// Delete comment from database
@@ -186,3 +378,205 @@ func (cc *CommentController) DeleteComment(c *gin.Context) {
 
 	util.RespondWithSuccess(c, http.StatusOK, "Comment deleted successfully", nil)
 }
+
+// HideComment hides a comment on the current user's post from everyone but its author
+func (cc *CommentController) HideComment(c *gin.Context) {
+	cc.setCommentHidden(c, true)
+}
+
+// UnhideComment shows a hidden comment on the current user's post again
+func (cc *CommentController) UnhideComment(c *gin.Context) {
+	cc.setCommentHidden(c, false)
+}
+
+// setCommentHidden hides or shows a comment on behalf of the post owner
+func (cc *CommentController) setCommentHidden(c *gin.Context, hidden bool) {
+	comment, ok := cc.findModeratedComment(c)
+	if !ok {
+		return
+	}
+
+	if err := cc.repo.Comment.SetHidden(comment, hidden); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to update comment")
+		return
+	}
+
+	message := "Comment shown successfully"
+	if hidden {
+		message = "Comment hidden successfully"
+	}
+	util.RespondWithSuccess(c, http.StatusOK, message, comment)
+}
+
+// PinComment pins a comment to the top of the comments on the current user's post
+func (cc *CommentController) PinComment(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	post, ok := cc.findOwnedPost(c, userID)
+	if !ok {
+		return
+	}
+
+	var input model.PinnedCommentUpdate
+	if !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	comment, err := cc.repo.Comment.FindByID(input.CommentID)
+	if err != nil || comment.PostID != post.ID {
+		util.RespondWithError(c, http.StatusNotFound, "Comment not found on this post")
+		return
+	}
+
+	if comment.HiddenAt != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Hidden comments can't be pinned")
+		return
+	}
+
+	if err := cc.repo.Post.SetPinnedComment(post.ID, &comment.ID); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to pin comment")
+		return
+	}
+
+	comment.Pinned = true
+	util.RespondWithSuccess(c, http.StatusOK, "Comment pinned successfully", comment)
+}
+
+// UnpinComment unpins the pinned comment of the current user's post
+func (cc *CommentController) UnpinComment(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	post, ok := cc.findOwnedPost(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := cc.repo.Post.SetPinnedComment(post.ID, nil); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to unpin comment")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Comment unpinned successfully", nil)
+}
+
+// UpdateCommentPolicy changes who may comment on the current user's post
+func (cc *CommentController) UpdateCommentPolicy(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	post, ok := cc.findOwnedPost(c, userID)
+	if !ok {
+		return
+	}
+
+	var input model.CommentPolicyUpdate
+	if !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	if err := cc.repo.Post.SetCommentPolicy(post.ID, input.Policy); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to update comment policy")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Comment policy updated successfully", gin.H{
+		"commentPolicy": input.Policy,
+	})
+}
+
+// checkCommentPolicy responds with an error when the post's comment policy doesn't let
+// the user comment. Post owners can always comment on their own posts.
+func (cc *CommentController) checkCommentPolicy(c *gin.Context, post *model.Post, userID uuid.UUID) bool {
+	if post.UserID == userID {
+		return true
+	}
+
+	switch post.CommentPolicy {
+	case model.CommentPolicyDisabled:
+		util.RespondWithError(c, http.StatusForbidden, "Comments are turned off for this post")
+		return false
+	case model.CommentPolicyFollowers:
+		following, err := cc.repo.User.IsFollowing(userID, post.UserID)
+		if err != nil {
+			util.RespondWithError(c, http.StatusInternalServerError, util.ErrorMessages.DatabaseError)
+			return false
+		}
+		if !following {
+			util.RespondWithError(c, http.StatusForbidden, "Only followers of the author can comment on this post")
+			return false
+		}
+	case model.CommentPolicyMentioned:
+		user, err := cc.repo.User.FindByID(userID)
+		if err != nil {
+			util.RespondWithError(c, http.StatusInternalServerError, util.ErrorMessages.DatabaseError)
+			return false
+		}
+		if !util.IsMentioned(post.Content, user.Username) {
+			util.RespondWithError(c, http.StatusForbidden, "Only people mentioned in this post can comment on it")
+			return false
+		}
+	}
+	return true
+}
+
+// findOwnedPost loads the post in the route and checks that the user owns it
+func (cc *CommentController) findOwnedPost(c *gin.Context, userID uuid.UUID) (*model.Post, bool) {
+	postID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return nil, false
+	}
+
+	post, err := cc.repo.Post.FindByID(postID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post not found")
+		return nil, false
+	}
+
+	if post.UserID != userID {
+		util.RespondWithError(c, http.StatusForbidden, "Only the author of the post can moderate its comments")
+		return nil, false
+	}
+	return post, true
+}
+
+// findModeratedComment loads the comment in the route and checks that the current user
+// owns the post it was made on
+func (cc *CommentController) findModeratedComment(c *gin.Context) (*model.Comment, bool) {
+	commentID, err := middleware.ParseUUIDParam(c, "commentId")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid comment ID format")
+		return nil, false
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return nil, false
+	}
+
+	comment, err := cc.repo.Comment.FindByID(commentID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Comment not found")
+		return nil, false
+	}
+
+	post, err := cc.repo.Post.FindByID(comment.PostID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post not found")
+		return nil, false
+	}
+
+	if post.UserID != userID {
+		util.RespondWithError(c, http.StatusForbidden, "Only the author of the post can moderate its comments")
+		return nil, false
+	}
+	return comment, true
+}
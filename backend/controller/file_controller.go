@@ -3,117 +3,579 @@ package controller
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"io"
 	"log"
 	"net/http"
+	"path"
 	"path/filepath"
 	"socialnet/config"
+	"socialnet/middleware"
+	"socialnet/model"
+	"socialnet/repository"
+	"socialnet/scanner"
+	"socialnet/storage"
 	"socialnet/util"
 	"strings"
 	"time"
 )
 
-var validExts = map[string]struct{}{".jpg": {}, ".jpeg": {}, ".png": {}, ".gif": {}, ".webp": {}}
+const (
+	// uploadKeyPrefix is the object key prefix under which public uploads are stored
+	uploadKeyPrefix = "public/uploads/"
+	// maxUploadSize is the largest file accepted by the upload endpoints
+	maxUploadSize = 5 * 1024 * 1024
+	// presignExpiry is how long a presigned upload URL stays valid
+	presignExpiry = 15 * time.Minute
+)
 
 type FileController struct {
-	s3Client *s3.Client
-	cfg      *config.Config
+	repo    *repository.Repository
+	store   storage.Storage
+	scanner scanner.Scanner
+	cfg     *config.Config
 }
 
-// NewFileController creates a new file controller
-func NewFileController(cfg *config.Config) *FileController {
-	var loadOptions []func(*awsconfig.LoadOptions) error
-	if cfg.AWS.AccessKeyID != "" {
-		loadOptions = append(loadOptions, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey, "")))
+// NewFileController creates a new file controller. The scanner may be nil to disable
+// malware scanning.
+func NewFileController(repo *repository.Repository, store storage.Storage, fileScanner scanner.Scanner, cfg *config.Config) *FileController {
+	return &FileController{
+		repo:    repo,
+		store:   store,
+		scanner: fileScanner,
+		cfg:     cfg,
 	}
+}
 
-	if cfg.AWS.Region != "" {
-		loadOptions = append(loadOptions, awsconfig.WithRegion(cfg.AWS.Region))
+// UploadFile handles file upload and returns the file URL
+func (fc *FileController) UploadFile(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
 	}
 
-	if cfg.AWS.Endpoint != "" {
-		loadOptions = append(loadOptions, awsconfig.WithBaseEndpoint(cfg.AWS.Endpoint))
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "No file provided")
+		return
 	}
+	defer file.Close()
 
-	c, err := awsconfig.LoadDefaultConfig(context.Background(), loadOptions...)
+	// Check file size (limit to 5MB)
+	if header.Size > maxUploadSize {
+		util.RespondWithError(c, http.StatusBadRequest, "File too large (max 5MB)")
+		return
+	}
 
+	// Read file content
+	fileContent, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
 	if err != nil {
-		panic(fmt.Sprintf("Failed to create AWS session: %v", err))
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to read file")
+		return
 	}
 
-	s3Client := s3.NewFromConfig(c)
+	if len(fileContent) > maxUploadSize {
+		util.RespondWithError(c, http.StatusBadRequest, "File too large (max 5MB)")
+		return
+	}
 
-	return &FileController{
-		s3Client: s3Client,
-		cfg:      cfg,
+	// Validate file type by its content rather than its name
+	if _, err := util.DetectImage(fileContent, header.Filename); err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, invalidImageMessage(err))
+		return
 	}
-}
 
-// UploadFile handles file upload and returns the file URL
-func (fc *FileController) UploadFile(c *gin.Context) {
-	file, header, err := c.Request.FormFile("file")
+	// Scan the file in memory so nothing is stored before it is known to be clean
+	infected, err := fc.scanUpload(c, userID, header.Filename, fileContent)
 	if err != nil {
-		util.RespondWithError(c, http.StatusBadRequest, "No file provided")
+		util.RespondWithError(c, http.StatusServiceUnavailable, "File could not be scanned, try again later")
+		return
+	}
+	if infected {
+		util.RespondWithError(c, http.StatusBadRequest, "File was rejected by the malware scanner")
 		return
 	}
-	defer file.Close()
 
-	// Check file size (limit to 5MB)
-	if header.Size > 5*1024*1024 {
+	// Decode, auto-orient and strip metadata before anything is stored
+	variants, err := util.ProcessImage(fileContent)
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid image file")
+		return
+	}
+
+	var totalSize int64
+	for _, variant := range variants {
+		totalSize += int64(len(variant.Data))
+	}
+	if !fc.reserveUploadQuota(c, userID, totalSize) {
+		return
+	}
+
+	upload := model.Upload{UserID: userID, Status: model.UploadStatusReady}
+	variantURLs, err := fc.storeImageVariants(c, &upload, variants)
+	if err != nil {
+		log.Printf("Failed to store uploaded file: %v", err)
+		fc.releaseUploadQuota(userID, totalSize)
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to upload file")
+		return
+	}
+
+	if err := fc.repo.Upload.Create(&upload); err != nil {
+		fc.deleteObjects(c, upload.Keys())
+		fc.releaseUploadQuota(userID, totalSize)
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to upload file")
+		return
+	}
+
+	original := variants[len(variants)-1]
+	util.RespondWithSuccess(c, http.StatusOK, "File uploaded successfully", gin.H{
+		"id":       upload.ID,
+		"url":      fc.store.URL(upload.Key),
+		"filename": path.Base(upload.Key),
+		"width":    original.Width,
+		"height":   original.Height,
+		"variants": variantURLs,
+	})
+}
+
+// storeImageVariants stores processed image variants under a new shared base name and
+// sets the upload's key, variants, content type and size from them. The largest variant
+// is served as the file itself. If any variant fails, the ones stored are removed again.
+func (fc *FileController) storeImageVariants(c *gin.Context, upload *model.Upload, variants []util.ProcessedImage) (map[string]string, error) {
+	baseName := fmt.Sprintf("%s-%s", time.Now().Format("20060102"), uuid.New().String())
+
+	var storedKeys []string
+	var storedSize int64
+	variantKeys := make(map[string]string, len(util.ImageVariants))
+	variantURLs := make(map[string]string, len(util.ImageVariants))
+	for _, variant := range variants {
+		fileKey := fmt.Sprintf("%s%s-%s%s", uploadKeyPrefix, baseName, variant.Name, variant.Ext)
+		if err := fc.store.Put(c, fileKey, bytes.NewReader(variant.Data), int64(len(variant.Data)), variant.ContentType); err != nil {
+			fc.deleteObjects(c, storedKeys)
+			return nil, err
+		}
+
+		storedKeys = append(storedKeys, fileKey)
+		storedSize += int64(len(variant.Data))
+		for _, name := range append([]string{variant.Name}, variant.Aliases...) {
+			variantKeys[name] = fileKey
+			variantURLs[name] = fc.store.URL(fileKey)
+		}
+	}
+
+	original := variants[len(variants)-1]
+	upload.Key = variantKeys[original.Name]
+	upload.ContentType = original.ContentType
+	upload.Size = storedSize
+	upload.Variants = variantKeys
+	return variantURLs, nil
+}
+
+// PresignUpload registers a pending upload and returns a presigned URL that the
+// client uses to upload the file directly to storage
+func (fc *FileController) PresignUpload(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	var input model.UploadPresign
+	if !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	if input.Size > maxUploadSize {
 		util.RespondWithError(c, http.StatusBadRequest, "File too large (max 5MB)")
 		return
 	}
 
 	// Validate file type
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	_, found := validExts[ext]
+	ext := strings.ToLower(filepath.Ext(input.Filename))
+	contentType, found := util.ImageContentType(ext)
 	if !found {
 		util.RespondWithError(c, http.StatusBadRequest, "Invalid file type (only .jpg, .png, .gif and .webp)")
 		return
 	}
 
-	// Read file content
-	fileContent, err := io.ReadAll(file)
-	if err != nil {
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to read file")
+	if input.ContentType != contentType {
+		util.RespondWithError(c, http.StatusBadRequest, "Content type does not match file extension")
+		return
+	}
+
+	if !fc.reserveUploadQuota(c, userID, input.Size) {
 		return
 	}
 
-	// Generate unique filename
-	uniqueID := uuid.New().String()
-	fileName := fmt.Sprintf("%s-%s%s", time.Now().Format("20060102"), uniqueID, ext)
-	fileKey := fmt.Sprintf("public/uploads/%s", fileName)
+	fileName := fmt.Sprintf("%s-%s%s", time.Now().Format("20060102"), uuid.New().String(), ext)
+	upload := model.Upload{
+		UserID:      userID,
+		Key:         uploadKeyPrefix + fileName,
+		ContentType: contentType,
+		Size:        input.Size,
+		Status:      model.UploadStatusPending,
+	}
 
-	// Upload to S3
-	contentType := http.DetectContentType(fileContent)
-	_, err = fc.s3Client.PutObject(c, &s3.PutObjectInput{
-		Bucket:      &fc.cfg.AWS.Bucket,
-		Key:         &fileKey,
-		Body:        bytes.NewReader(fileContent),
-		ContentType: &contentType,
+	if err := fc.repo.Upload.Create(&upload); err != nil {
+		fc.releaseUploadQuota(userID, input.Size)
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+
+	// The client writes to a private key; the file is only published under its public key
+	// once CompleteUpload has verified and scanned it
+	uploadURL, err := fc.store.PresignPut(c, upload.QuarantineKey(), upload.ContentType, upload.Size, presignExpiry)
+	if err != nil {
+		log.Printf("Failed to presign upload: %v", err)
+		// Deleting the record gives its storage back
+		if err := fc.repo.Upload.Delete(upload.ID); err != nil {
+			log.Printf("Failed to delete upload %s: %v", upload.ID, err)
+		} else if err := fc.repo.Upload.ReleaseDaily(userID, uploadDay(time.Now()), upload.Size); err != nil {
+			log.Printf("Failed to release daily uploads of user %s: %v", userID, err)
+		}
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusCreated, "Upload URL created successfully", gin.H{
+		"id":        upload.ID,
+		"uploadUrl": uploadURL,
+		"method":    http.MethodPut,
+		"headers":   gin.H{"Content-Type": upload.ContentType},
+		"expiresAt": time.Now().Add(presignExpiry),
 	})
+}
 
+// CompleteUpload verifies a file uploaded through a presigned URL and marks it usable
+func (fc *FileController) CompleteUpload(c *gin.Context) {
+	uploadID, err := middleware.ParseUUIDParam(c, "id")
 	if err != nil {
-		log.Printf("Failed to upload file to S3: %v", err)
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to upload file")
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid upload ID format")
 		return
 	}
 
-	cdnURL := fc.cfg.AWS.CdnURL
-	if cdnURL == "" {
-		cdnURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", fc.cfg.AWS.Bucket, fc.cfg.AWS.Region)
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	upload, err := fc.repo.Upload.FindByID(uploadID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Upload not found")
+		return
+	}
+
+	if !middleware.CheckResourceOwnership(c, upload.UserID, userID) {
+		return
+	}
+
+	if upload.Status == model.UploadStatusPending {
+		quarantineKey := upload.QuarantineKey()
+		info, err := fc.store.Stat(c, quarantineKey)
+		if errors.Is(err, storage.ErrNotFound) {
+			util.RespondWithError(c, http.StatusBadRequest, "File has not been uploaded yet")
+			return
+		}
+		if err != nil {
+			util.RespondWithError(c, http.StatusInternalServerError, "Failed to verify upload", err)
+			return
+		}
+
+		content, reason := fc.verifyStoredUpload(c, upload, info)
+		if reason == "" {
+			infected, err := fc.scanUpload(c, userID, quarantineKey, content)
+			if err != nil {
+				util.RespondWithError(c, http.StatusServiceUnavailable, "File could not be scanned, try again later")
+				return
+			}
+			if infected {
+				reason = "File was rejected by the malware scanner"
+			}
+		}
+
+		// Decode, auto-orient and strip metadata before anything is published
+		var variants []util.ProcessedImage
+		if reason == "" {
+			if variants, err = util.ProcessImage(content); err != nil {
+				reason = "Invalid image file"
+			}
+		}
+
+		if reason != "" {
+			// Drop the invalid object so it can't be referenced later
+			fc.deleteObjects(c, []string{quarantineKey})
+			if err := fc.repo.Upload.Delete(upload.ID); err != nil {
+				log.Printf("Failed to delete rejected upload %s: %v", upload.ID, err)
+			}
+			util.RespondWithError(c, http.StatusBadRequest, reason)
+			return
+		}
+
+		// Publish the re-encoded variants
+		if _, err := fc.storeImageVariants(c, upload, variants); err != nil {
+			log.Printf("Failed to publish upload %s: %v", upload.ID, err)
+			util.RespondWithError(c, http.StatusInternalServerError, "Failed to complete upload")
+			return
+		}
+
+		err = fc.repo.Upload.MarkReady(upload)
+		if err != nil {
+			// Only the recorded objects are ever reclaimed, so ours must go
+			fc.deleteObjects(c, upload.Keys())
+		}
+		if errors.Is(err, repository.ErrUploadNotPending) {
+			// A concurrent or retried request completed the upload first; answer with its result
+			if upload, err = fc.repo.Upload.FindByID(upload.ID); err != nil {
+				util.RespondWithError(c, http.StatusNotFound, "Upload not found")
+				return
+			}
+		} else if err != nil {
+			util.RespondWithError(c, http.StatusInternalServerError, "Failed to complete upload")
+			return
+		}
+
+		// Release the quarantined copy once the published one is recorded
+		fc.deleteObjects(c, []string{quarantineKey})
+	}
+
+	variantURLs := make(map[string]string, len(upload.Variants))
+	for name, key := range upload.Variants {
+		variantURLs[name] = fc.store.URL(key)
 	}
-	// Generate file URL
-	fileURL := fmt.Sprintf("%s/%s", cdnURL, fileKey)
 
 	util.RespondWithSuccess(c, http.StatusOK, "File uploaded successfully", gin.H{
-		"url":      fileURL,
-		"filename": fileName,
+		"id":       upload.ID,
+		"url":      fc.store.URL(upload.Key),
+		"filename": path.Base(upload.Key),
+		"status":   upload.Status,
+		"variants": variantURLs,
 	})
 }
+
+// DeleteUpload deletes an upload of the current user that nothing references
+func (fc *FileController) DeleteUpload(c *gin.Context) {
+	uploadID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid upload ID format")
+		return
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	upload, err := fc.repo.Upload.FindByID(uploadID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Upload not found")
+		return
+	}
+
+	if !middleware.CheckResourceOwnership(c, upload.UserID, userID) {
+		return
+	}
+
+	deleted, err := fc.repo.Upload.DeleteUnreferenced(upload.ID, userID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to delete upload")
+		return
+	}
+	if !deleted {
+		util.RespondWithError(c, http.StatusConflict, "Upload is in use")
+		return
+	}
+
+	fc.deleteObjects(c, upload.Keys())
+
+	util.RespondWithSuccess(c, http.StatusOK, "Upload deleted successfully", nil)
+}
+
+// reserveUploadQuota reserves size bytes of the user's storage quota and counts an upload
+// against their daily limits, responding with an error if it does not fit. Callers give
+// the reservation back with releaseUploadQuota if the upload is not recorded after all.
+func (fc *FileController) reserveUploadQuota(c *gin.Context, userID uuid.UUID, size int64) bool {
+	return reserveUploadQuota(c, fc.repo, fc.cfg, userID, size)
+}
+
+// releaseUploadQuota gives back a reservation for an upload that could not be stored
+func (fc *FileController) releaseUploadQuota(userID uuid.UUID, size int64) {
+	releaseUploadQuota(fc.repo, userID, size)
+}
+
+// reserveUploadQuota reserves storage and daily upload quota for an upload of size bytes
+func reserveUploadQuota(c *gin.Context, repo *repository.Repository, cfg *config.Config, userID uuid.UUID, size int64) bool {
+	limits := cfg.Storage
+
+	reserved, err := repo.Upload.ReserveStorage(userID, size, limits.QuotaBytes)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to check storage quota")
+		return false
+	}
+	if !reserved {
+		used, _, _ := repo.Upload.StorageUsed(userID)
+		util.RespondWithError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf(
+			"Storage quota exceeded (%s of %s used); delete unused uploads to free space",
+			formatMegabytes(used), formatMegabytes(limits.QuotaBytes)))
+		return false
+	}
+
+	reserved, err = repo.Upload.ReserveDaily(userID, uploadDay(time.Now()), size, limits.DailyUploadLimit, limits.DailyUploadBytes)
+	if err != nil || !reserved {
+		if err := repo.Upload.ReleaseStorage(userID, size); err != nil {
+			log.Printf("Failed to release storage of user %s: %v", userID, err)
+		}
+	}
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to check upload limits")
+		return false
+	}
+	if !reserved {
+		var parts []string
+		if limits.DailyUploadLimit > 0 {
+			parts = append(parts, fmt.Sprintf("%d files", limits.DailyUploadLimit))
+		}
+		if limits.DailyUploadBytes > 0 {
+			parts = append(parts, formatMegabytes(limits.DailyUploadBytes))
+		}
+		util.RespondWithError(c, http.StatusTooManyRequests, fmt.Sprintf(
+			"Daily upload limit reached (%s per day); try again tomorrow", strings.Join(parts, " or ")))
+		return false
+	}
+
+	return true
+}
+
+// releaseUploadQuota gives back the storage and daily quota reserved for an upload of
+// size bytes that was never recorded
+func releaseUploadQuota(repo *repository.Repository, userID uuid.UUID, size int64) {
+	if err := repo.Upload.ReleaseStorage(userID, size); err != nil {
+		log.Printf("Failed to release storage of user %s: %v", userID, err)
+	}
+	if err := repo.Upload.ReleaseDaily(userID, uploadDay(time.Now()), size); err != nil {
+		log.Printf("Failed to release daily uploads of user %s: %v", userID, err)
+	}
+}
+
+// storageUsage reports a user's storage usage and quotas
+func storageUsage(repo *repository.Repository, cfg *config.Config, userID uuid.UUID) (*model.StorageUsage, error) {
+	used, count, err := repo.Upload.StorageUsed(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	daily, err := repo.Upload.DailyUsage(userID, uploadDay(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.StorageUsage{
+		UsedBytes:        used,
+		QuotaBytes:       cfg.Storage.QuotaBytes,
+		FileCount:        count,
+		UploadsToday:     daily.Count,
+		DailyUploadLimit: cfg.Storage.DailyUploadLimit,
+		BytesToday:       daily.Bytes,
+		DailyBytesLimit:  cfg.Storage.DailyUploadBytes,
+	}, nil
+}
+
+// uploadDay returns the UTC day daily upload limits are counted against
+func uploadDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// formatMegabytes formats a byte count for quota messages
+func formatMegabytes(bytes int64) string {
+	return fmt.Sprintf("%.1fMB", float64(bytes)/(1024*1024))
+}
+
+// verifyStoredUpload applies the upload validation rules to an object stored through a
+// presigned URL and returns its content, or the reason it was rejected
+func (fc *FileController) verifyStoredUpload(ctx context.Context, upload *model.Upload, info *storage.ObjectInfo) ([]byte, string) {
+	if info.Size > maxUploadSize {
+		return nil, "File too large (max 5MB)"
+	}
+
+	if info.Size != upload.Size || (info.ContentType != "" && info.ContentType != upload.ContentType) {
+		return nil, "Uploaded file does not match the requested upload"
+	}
+
+	object, err := fc.store.GetRange(ctx, upload.QuarantineKey(), 0, info.Size)
+	if err != nil {
+		log.Printf("Failed to read upload %s: %v", upload.ID, err)
+		return nil, "Failed to verify uploaded file"
+	}
+	defer object.Close()
+
+	content, err := io.ReadAll(io.LimitReader(object, maxUploadSize))
+	if err != nil {
+		log.Printf("Failed to read upload %s: %v", upload.ID, err)
+		return nil, "Failed to verify uploaded file"
+	}
+
+	detected, err := util.DetectImage(content, upload.Key)
+	if err != nil {
+		return nil, invalidImageMessage(err)
+	}
+
+	if detected.ContentType != upload.ContentType {
+		return nil, "File content does not match its type"
+	}
+
+	return content, ""
+}
+
+// scanUpload runs the malware scanner over an upload and reports whether it is infected.
+// Infected files are logged; an error means the file could not be scanned.
+func (fc *FileController) scanUpload(ctx context.Context, userID uuid.UUID, name string, content []byte) (bool, error) {
+	if fc.scanner == nil {
+		return false, nil
+	}
+
+	result, err := fc.scanner.Scan(ctx, bytes.NewReader(content))
+	if err != nil {
+		log.Printf("Failed to scan upload %s: %v", name, err)
+		return false, err
+	}
+
+	if !result.Clean {
+		log.Printf("Rejected infected upload %s from user %s: %s", name, userID, result.Signature)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// invalidImageMessage returns the client facing message for an image validation error
+func invalidImageMessage(err error) string {
+	switch {
+	case errors.Is(err, util.ErrExtensionMismatch):
+		return "File extension does not match its content"
+	case errors.Is(err, util.ErrImageTooLarge):
+		return fmt.Sprintf("Image dimensions too large (max %dx%d pixels)", util.MaxImageDimension, util.MaxImageDimension)
+	case errors.Is(err, util.ErrEmbeddedContent):
+		return "File contains embedded content that is not allowed"
+	default:
+		return "Invalid file type (only .jpg, .png, .gif and .webp)"
+	}
+}
+
+// deleteObjects removes already stored objects after a partially failed upload
+func (fc *FileController) deleteObjects(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := fc.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to clean up stored object %s: %v", key, err)
+		}
+	}
+}
+
+// uploadKeyFromURL returns the object key of a URL pointing at a public upload
+func uploadKeyFromURL(store storage.Storage, fileURL string) (string, bool) {
+	key, ok := storage.KeyFromURL(store, fileURL)
+	if !ok || !strings.HasPrefix(key, uploadKeyPrefix) || len(key) == len(uploadKeyPrefix) {
+		return "", false
+	}
+	return key, true
+}
@@ -181,20 +181,19 @@ func (mc *MessageController) GetMessages(c *gin.Context) {
 
 	// Parse filter parameters
 	var filter model.MessageFilter
-	if err := c.ShouldBindQuery(&filter); err != nil {
-		util.RespondWithError(c, http.StatusBadRequest, err.Error())
+	if !middleware.BindQuery(c, &filter) {
 		return
 	}
 	filter.ConversationID = convID
 
 	// Get messages
-	messages, err := mc.repo.Message.FindMessages(convID, filter)
+	messages, meta, err := mc.repo.Message.FindMessages(convID, filter)
 	if err != nil {
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch messages", err)
+		util.RespondWithListError(c, err, "Failed to fetch messages")
 		return
 	}
 
-	util.RespondWithSuccess(c, http.StatusOK, "success", messages)
+	util.RespondWithPagination(c, http.StatusOK, "success", messages, meta)
 }
 
 // CreateMessage sends a new message in a conversation
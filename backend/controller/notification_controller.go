@@ -38,13 +38,13 @@ func (nc *NotificationController) GetNotifications(c *gin.Context) {
 	}
 
 	// Query notifications from database
-	notifications, err := nc.repo.Notification.FindByUserID(userID, filter)
+	notifications, meta, err := nc.repo.Notification.FindByUserID(userID, filter)
 	if err != nil {
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch notifications")
+		util.RespondWithListError(c, err, "Failed to fetch notifications")
 		return
 	}
 
-	util.RespondWithSuccess(c, http.StatusOK, "Notifications retrieved successfully", notifications)
+	util.RespondWithPagination(c, http.StatusOK, "Notifications retrieved successfully", notifications, meta)
 }
 
 // GetUnreadCount returns the count of unread notifications
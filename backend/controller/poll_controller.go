@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"socialnet/middleware"
+	"socialnet/model"
+	"socialnet/repository"
+	"socialnet/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPoll returns the poll of a post as seen by the current user
+func (pic *PostInteractionController) GetPoll(c *gin.Context) {
+	postID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	post, err := pic.repo.Post.FindByID(postID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	if post.Poll == nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post has no poll")
+		return
+	}
+
+	if _, err := pic.repo.Poll.FillPollInfo(middleware.GetOptionalUserID(c), []model.Post{*post}); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch poll")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Poll retrieved successfully", post.Poll)
+}
+
+// VotePoll casts the current user's vote in the poll of a post and returns the results
+func (pic *PostInteractionController) VotePoll(c *gin.Context) {
+	postID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	post, err := pic.repo.Post.FindByID(postID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	if post.Poll == nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post has no poll")
+		return
+	}
+
+	var input model.PollVoteInput
+	if !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	err = pic.repo.Poll.Vote(post.Poll.ID, userID, input.OptionIDs)
+	switch {
+	case errors.Is(err, repository.ErrPollClosed):
+		util.RespondWithError(c, http.StatusConflict, "Poll is closed")
+		return
+	case errors.Is(err, repository.ErrAlreadyVoted):
+		util.RespondWithError(c, http.StatusConflict, "Already voted in this poll")
+		return
+	case errors.Is(err, repository.ErrInvalidPollChoice):
+		if post.Poll.MultipleChoice {
+			util.RespondWithError(c, http.StatusBadRequest, "Choose one or more options of this poll")
+		} else {
+			util.RespondWithError(c, http.StatusBadRequest, "Choose exactly one option of this poll")
+		}
+		return
+	case err != nil:
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to vote")
+		return
+	}
+
+	poll, err := pic.repo.Poll.FindByPostID(postID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch poll")
+		return
+	}
+
+	if _, err := pic.repo.Poll.FillPollInfo(&userID, []model.Post{{ID: postID, Poll: poll}}); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch poll")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusCreated, "Vote recorded successfully", poll)
+}
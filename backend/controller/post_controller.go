@@ -12,20 +12,45 @@ type PostControllerInterface interface {
 	CreatePost(c *gin.Context)
 	UpdatePost(c *gin.Context)
 	DeletePost(c *gin.Context)
+	GetPostRevisions(c *gin.Context)
+	GetThread(c *gin.Context)
+	GetDrafts(c *gin.Context)
+	CreateDraft(c *gin.Context)
+	UpdateDraft(c *gin.Context)
+	DeleteDraft(c *gin.Context)
+	PublishDraft(c *gin.Context)
 	GetFeed(c *gin.Context)
+	GetNewPostCount(c *gin.Context)
 	GetTrending(c *gin.Context)
 	GetSuggestedPosts(c *gin.Context)
 
 	// Post interaction operations
 	LikePost(c *gin.Context)
 	UnlikePost(c *gin.Context)
+	ReactToPost(c *gin.Context)
+	RemoveReaction(c *gin.Context)
+	GetReactions(c *gin.Context)
 	SharePost(c *gin.Context)
+	UnsharePost(c *gin.Context)
+	GetReposters(c *gin.Context)
+	GetQuotes(c *gin.Context)
+	GetPoll(c *gin.Context)
+	VotePoll(c *gin.Context)
 
 	// Comment operations
 	GetComments(c *gin.Context)
 	CreateComment(c *gin.Context)
+	GetReplies(c *gin.Context)
+	LikeComment(c *gin.Context)
+	UnlikeComment(c *gin.Context)
 	UpdateComment(c *gin.Context)
+	GetCommentRevisions(c *gin.Context)
 	DeleteComment(c *gin.Context)
+	HideComment(c *gin.Context)
+	UnhideComment(c *gin.Context)
+	PinComment(c *gin.Context)
+	UnpinComment(c *gin.Context)
+	UpdateCommentPolicy(c *gin.Context)
 }
 
 // SearchControllerInterface encapsulates search operations
@@ -1,37 +1,53 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"socialnet/util"
+	"strings"
+	"time"
 
 	"socialnet/config"
 	"socialnet/middleware"
 	"socialnet/model"
+	"socialnet/ranking"
 	"socialnet/repository"
+	"socialnet/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // PostController handles post-related requests
 type PostController struct {
-	repo *repository.Repository
-	cfg  *config.Config
+	repo   *repository.Repository
+	store  storage.Storage
+	scorer ranking.Scorer
+	cfg    *config.Config
 }
 
 // NewPostController creates a new PostController
-func NewPostController(repo *repository.Repository, cfg *config.Config) *PostController {
+func NewPostController(repo *repository.Repository, store storage.Storage, cfg *config.Config) *PostController {
 	return &PostController{
-		repo: repo,
-		cfg:  cfg,
+		repo:  repo,
+		store: store,
+		scorer: ranking.NewWeightedScorer(ranking.Weights{
+			Recency:  cfg.Ranking.RecencyWeight,
+			Velocity: cfg.Ranking.VelocityWeight,
+			Affinity: cfg.Ranking.AffinityWeight,
+			HalfLife: cfg.Ranking.HalfLife,
+		}),
+		cfg: cfg,
 	}
 }
 
 // GetPosts returns a list of posts
 func (pc *PostController) GetPosts(c *gin.Context) {
 	var filter model.PostFilter
-	if err := c.ShouldBindQuery(&filter); err != nil {
-		util.RespondWithError(c, http.StatusBadRequest, err.Error())
+	if !middleware.BindQuery(c, &filter) {
 		return
 	}
 
@@ -44,18 +60,18 @@ func (pc *PostController) GetPosts(c *gin.Context) {
 	}
 
 	// Query posts from database
-	posts, err := pc.repo.Post.FindAll(filter)
+	posts, meta, err := pc.repo.Post.FindAll(filter)
 	if err != nil {
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch posts")
+		util.RespondWithListError(c, err, "Failed to fetch posts")
 		return
 	}
 
-	if posts, err = pc.repo.Post.FillLikeInfo(currentUserID, posts); err != nil {
+	if posts, err = fillViewerInfo(pc.repo, currentUserID, posts); err != nil {
 		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch like info")
 		return
 	}
 
-	util.RespondWithSuccess(c, http.StatusOK, "success", posts)
+	util.RespondWithPagination(c, http.StatusOK, "success", posts, meta)
 }
 
 // GetPost returns a specific post by ID
@@ -82,11 +98,7 @@ func (pc *PostController) GetPost(c *gin.Context) {
 		return
 	}
 
-	// If user is authenticated, check if post is liked
-	if currentUserID != nil {
-		isLiked, _ := pc.repo.Post.IsLiked(*currentUserID, post.ID)
-		post.IsLiked = &isLiked
-	}
+	fillPostViewerInfo(pc.repo, currentUserID, post)
 
 	util.RespondWithSuccess(c, http.StatusOK, "success", post)
 }
@@ -107,12 +119,34 @@ func (pc *PostController) CreatePost(c *gin.Context) {
 		return
 	}
 
+	media, image, ok := pc.buildPostMedia(c, userID, nil, input.Media, input.Image)
+	if !ok {
+		return
+	}
+
+	poll, ok := buildPoll(c, input.Poll)
+	if !ok {
+		return
+	}
+
+	// Replies go to published posts only
+	if input.InReplyToID != nil {
+		if _, err := pc.repo.Post.FindByID(*input.InReplyToID); err != nil {
+			util.RespondWithError(c, http.StatusNotFound, "Post to reply to not found")
+			return
+		}
+	}
+
 	// Create new post
 	post := model.Post{
-		ID:      uuid.New(),
-		UserID:  userID,
-		Content: input.Content,
-		Image:   input.Image,
+		ID:            uuid.New(),
+		UserID:        userID,
+		Content:       input.Content,
+		Image:         image,
+		Media:         media,
+		Poll:          poll,
+		InReplyToID:   input.InReplyToID,
+		CommentPolicy: input.CommentPolicy,
 	}
 
 	// Save post to database
@@ -129,9 +163,7 @@ func (pc *PostController) CreatePost(c *gin.Context) {
 		return
 	}
 
-	// Set is_liked to true since user just created it
-	isLiked := false
-	createdPost.IsLiked = &isLiked
+	fillPostViewerInfo(pc.repo, &userID, createdPost)
 
 	c.JSON(http.StatusCreated, createdPost)
 }
@@ -172,9 +204,15 @@ func (pc *PostController) UpdatePost(c *gin.Context) {
 		return
 	}
 
+	media, image, ok := pc.buildPostMedia(c, userID, post, input.Media, input.Image)
+	if !ok {
+		return
+	}
+
 	// Update post fields
 	post.Content = input.Content
-	post.Image = input.Image
+	post.Image = image
+	post.Media = media
 
 	// Save updated post to database
 	err = pc.repo.Post.Update(post)
@@ -183,13 +221,41 @@ func (pc *PostController) UpdatePost(c *gin.Context) {
 		return
 	}
 
-	// Check if post is liked
-	isLiked, _ := pc.repo.Post.IsLiked(userID, post.ID)
-	post.IsLiked = &isLiked
+	fillPostViewerInfo(pc.repo, &userID, post)
 
 	util.RespondWithSuccess(c, http.StatusOK, "success", post)
 }
 
+// GetPostRevisions returns the previous versions of a post, newest first, each with the
+// changes the following edit made to it
+func (pc *PostController) GetPostRevisions(c *gin.Context) {
+	id, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	post, err := pc.repo.Post.FindByID(id)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	revisions, err := pc.repo.Post.FindRevisions(post.ID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch revisions")
+		return
+	}
+
+	next := post.Content
+	for i := range revisions {
+		revisions[i].Diff = util.WordDiff(revisions[i].Content, next)
+		next = revisions[i].Content
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Revisions retrieved successfully", revisions)
+}
+
 // DeletePost deletes a post
 func (pc *PostController) DeletePost(c *gin.Context) {
 	idStr := c.Param("id")
@@ -240,34 +306,120 @@ func (pc *PostController) GetFeed(c *gin.Context) {
 
 	userID, _ := uuid.Parse(userIDStr)
 
-	var filter model.Pagination
-	if err := c.ShouldBindQuery(&filter); err != nil {
-		util.RespondWithError(c, http.StatusBadRequest, err.Error())
+	var filter model.FeedFilter
+	if !middleware.BindQuery(c, &filter) {
+		return
+	}
+
+	if filter.Mode == model.FeedModeRanked && filter.Cursor != "" {
+		util.RespondWithError(c, http.StatusBadRequest, "The ranked feed doesn't take a cursor; it is read as a single page")
 		return
 	}
 
 	// Get feed posts (posts from followed users and own posts)
-	posts, err := pc.repo.Post.FindFeed(userID, filter)
+	var posts []model.Post
+	var meta util.CursorMeta
+	if filter.Mode == model.FeedModeRanked {
+		posts, err = pc.findRankedFeed(userID, filter.Pagination)
+	} else {
+		posts, meta, err = pc.repo.Timeline.FindFeed(userID, pc.cfg.Timeline.PullThreshold, filter.Pagination)
+	}
 	if err != nil {
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch feed")
+		util.RespondWithListError(c, err, "Failed to fetch feed")
 		return
 	}
 
-	if posts, err = pc.repo.Post.FillLikeInfo(&userID, posts); err != nil {
+	if posts, err = fillViewerInfo(pc.repo, &userID, posts); err != nil {
 		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch like info")
 		return
 	}
 
-	util.RespondWithSuccess(c, http.StatusOK, "success", posts)
+	util.RespondWithPagination(c, http.StatusOK, "success", posts, meta)
 }
 
-// GetTrending returns trending posts based on engagement
+// GetNewPostCount returns how many posts by other accounts reached the authenticated
+// user's home timeline after the given time, so clients can offer to refresh the feed
+func (pc *PostController) GetNewPostCount(c *gin.Context) {
+	userIDStr, err := middleware.GetUserID(c)
+	if err != nil {
+		util.RespondWithError(c, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	userID, _ := uuid.Parse(userIDStr)
+
+	var filter model.NewPostsFilter
+	if !middleware.BindQuery(c, &filter) {
+		return
+	}
+
+	count, err := pc.repo.Timeline.CountNew(userID, pc.cfg.Timeline.PullThreshold, filter.Since)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to count new posts")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "success", gin.H{"count": count})
+}
+
+// findRankedFeed ranks the recent posts of a user's home timeline and returns a page.
+// The ranking is recomputed on every request and scores move as posts age and gain
+// engagement, so the ranked feed is meant to be read as a single page; later offsets
+// may repeat or skip posts.
+func (pc *PostController) findRankedFeed(userID uuid.UUID, page model.Pagination) ([]model.Post, error) {
+	now := time.Now()
+	posts, err := pc.repo.Timeline.FindCandidates(userID, pc.cfg.Timeline.PullThreshold, now.Add(-pc.cfg.Ranking.Window), pc.cfg.Ranking.Candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	authorIDs := make([]uuid.UUID, 0, len(posts))
+	seen := make(map[uuid.UUID]bool)
+	for _, post := range posts {
+		if !seen[post.UserID] {
+			seen[post.UserID] = true
+			authorIDs = append(authorIDs, post.UserID)
+		}
+	}
+
+	affinity, err := pc.repo.Timeline.FindAffinity(userID, authorIDs, now.Add(-pc.cfg.Ranking.AffinityWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]ranking.Candidate, len(posts))
+	for i, post := range posts {
+		candidates[i] = ranking.Candidate{
+			PostID:     post.ID,
+			AuthorID:   post.UserID,
+			CreatedAt:  post.CreatedAt,
+			Engagement: post.LikesCount + post.CommentsCount + post.SharesCount,
+			Affinity:   affinity[post.UserID],
+		}
+	}
+	ranked := ranking.Rank(candidates, pc.scorer, now, pc.cfg.Ranking.AuthorSpacing)
+
+	if page.Offset >= len(ranked) {
+		return []model.Post{}, nil
+	}
+	ranked = ranked[page.Offset:min(page.Offset+page.Limit, len(ranked))]
+
+	ids := make([]uuid.UUID, len(ranked))
+	for i, candidate := range ranked {
+		ids[i] = candidate.PostID
+	}
+	return pc.repo.Timeline.FindPosts(ids)
+}
+
+// GetTrending returns the posts with the most recent engagement within a window
 func (pc *PostController) GetTrending(c *gin.Context) {
-	var filter model.Pagination
-	if err := c.ShouldBindQuery(&filter); err != nil {
-		util.RespondWithError(c, http.StatusBadRequest, err.Error())
+	var filter model.TrendingFilter
+	if !middleware.BindQuery(c, &filter) {
 		return
 	}
+	if filter.Window == "" {
+		filter.Window = model.DefaultTrendingWindow
+	}
 
 	var currentUserID *uuid.UUID
 
@@ -278,18 +430,18 @@ func (pc *PostController) GetTrending(c *gin.Context) {
 	}
 
 	// Get trending posts
-	posts, err := pc.repo.Post.FindTrending(filter)
+	posts, meta, err := pc.repo.Trending.FindTrending(filter.Window, filter.Pagination)
 	if err != nil {
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch trending posts")
+		util.RespondWithListError(c, err, "Failed to fetch trending posts")
 		return
 	}
 
-	if posts, err = pc.repo.Post.FillLikeInfo(currentUserID, posts); err != nil {
+	if posts, err = fillViewerInfo(pc.repo, currentUserID, posts); err != nil {
 		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch like info")
 		return
 	}
 
-	util.RespondWithSuccess(c, http.StatusOK, "success", posts)
+	util.RespondWithPagination(c, http.StatusOK, "success", posts, meta)
 }
 
 // GetSuggestedPosts returns posts that might interest the user
@@ -303,8 +455,7 @@ func (pc *PostController) GetSuggestedPosts(c *gin.Context) {
 	userID, _ := uuid.Parse(userIDStr)
 
 	var filter model.Pagination
-	if err := c.ShouldBindQuery(&filter); err != nil {
-		util.RespondWithError(c, http.StatusBadRequest, err.Error())
+	if !middleware.BindQuery(c, &filter) {
 		return
 	}
 
@@ -315,10 +466,210 @@ func (pc *PostController) GetSuggestedPosts(c *gin.Context) {
 		return
 	}
 
-	if posts, err = pc.repo.Post.FillLikeInfo(&userID, posts); err != nil {
+	if posts, err = fillViewerInfo(pc.repo, &userID, posts); err != nil {
 		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch like info")
 		return
 	}
 
 	util.RespondWithSuccess(c, http.StatusOK, "success", posts)
 }
+
+// fillViewerInfo sets whether the current user liked, reposted and bookmarked each post
+// and voted in its poll
+func fillViewerInfo(repo *repository.Repository, userID *uuid.UUID, posts []model.Post) ([]model.Post, error) {
+	posts, err := repo.Post.FillLikeInfo(userID, posts)
+	if err != nil {
+		return nil, err
+	}
+	if posts, err = repo.Post.FillRepostInfo(userID, posts); err != nil {
+		return nil, err
+	}
+	if posts, err = repo.Bookmark.FillBookmarkInfo(userID, posts); err != nil {
+		return nil, err
+	}
+	return repo.Poll.FillPollInfo(userID, posts)
+}
+
+// fillPostViewerInfo fills the viewer info of a single post the same way fillViewerInfo
+// does for lists
+func fillPostViewerInfo(repo *repository.Repository, userID *uuid.UUID, post *model.Post) {
+	posts, err := fillViewerInfo(repo, userID, []model.Post{*post})
+	if err != nil {
+		log.Printf("Failed to fetch viewer info of post %s: %v", post.ID, err)
+		return
+	}
+	*post = posts[0]
+}
+
+// buildPoll converts poll input into a poll that closes after the requested duration
+func buildPoll(c *gin.Context, input *model.PollCreate) (*model.Poll, bool) {
+	if input == nil {
+		return nil, true
+	}
+
+	poll := &model.Poll{
+		MultipleChoice: input.MultipleChoice,
+		ExpiresAt:      time.Now().Add(time.Duration(input.DurationMinutes) * time.Minute),
+		Options:        make([]model.PollOption, len(input.Options)),
+	}
+
+	seen := make(map[string]bool, len(input.Options))
+	for i, text := range input.Options {
+		text = strings.TrimSpace(text)
+		if text == "" || seen[strings.ToLower(text)] {
+			util.RespondWithError(c, http.StatusBadRequest, "Poll options must be distinct and not empty")
+			return nil, false
+		}
+		seen[strings.ToLower(text)] = true
+		poll.Options[i] = model.PollOption{Position: i, Text: text}
+	}
+	return poll, true
+}
+
+// buildPostMedia converts attachment input into ordered post media. Clients that only
+// send the legacy image field get it as a single attachment, and the image field is
+// always set to the first attachment so that older clients keep rendering it. When
+// current is the post being edited, attachments it already has are kept even if they
+// predate the uploads registry.
+func (pc *PostController) buildPostMedia(c *gin.Context, userID uuid.UUID, current *model.Post, input []model.PostMediaInput, image *string) ([]model.PostMedia, *string, bool) {
+	if len(input) == 0 && image != nil && *image != "" {
+		input = []model.PostMediaInput{{URL: *image}}
+	}
+
+	if len(input) > model.MaxPostMedia {
+		util.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("A post can have at most %d attachments", model.MaxPostMedia))
+		return nil, nil, false
+	}
+
+	stored := storedPostMedia(current)
+	media := make([]model.PostMedia, 0, len(input))
+	var cover *string
+	for i, item := range input {
+		// Only the author's own uploads can be attached, and images only once verified
+		var upload *model.Upload
+		if key, ok := uploadKeyFromURL(pc.store, item.URL); ok {
+			found, err := pc.repo.Upload.FindByKey(key)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				util.RespondWithError(c, http.StatusInternalServerError, "Failed to check attachments")
+				return nil, nil, false
+			}
+			if err == nil && found.UserID == userID {
+				upload = found
+			}
+		}
+
+		if upload == nil {
+			// Attachments saved before uploads were registered stay as they are
+			kept, unchanged := stored[item.URL]
+			if !unchanged {
+				util.RespondWithError(c, http.StatusBadRequest, "Attachments must be uploaded through the uploads endpoint")
+				return nil, nil, false
+			}
+
+			kept.Position = i
+			kept.AltText = item.AltText
+			if cover == nil {
+				if kept.Type == model.MediaTypeImage {
+					cover = &kept.URL
+				} else {
+					cover = kept.PosterURL
+				}
+			}
+			media = append(media, kept)
+			continue
+		}
+
+		attachment := model.PostMedia{
+			Position: i,
+			URL:      item.URL,
+			Type:     model.MediaTypeImage,
+			Status:   model.MediaStatusReady,
+			Width:    item.Width,
+			Height:   item.Height,
+			AltText:  item.AltText,
+			Blurhash: item.Blurhash,
+		}
+
+		attachment.UploadID = upload.ID
+
+		// Videos take their type, state and dimensions from the upload rather than the client
+		if upload.Kind == model.UploadKindVideo || item.Type == model.MediaTypeVideo {
+			if !pc.applyVideoUpload(c, userID, upload, &attachment) {
+				return nil, nil, false
+			}
+		} else if upload.Status != model.UploadStatusReady {
+			util.RespondWithError(c, http.StatusBadRequest, "Attachments must be uploaded through the uploads endpoint")
+			return nil, nil, false
+		}
+
+		if cover == nil {
+			if attachment.Type == model.MediaTypeImage {
+				cover = &attachment.URL
+			} else {
+				cover = attachment.PosterURL
+			}
+		}
+
+		media = append(media, attachment)
+	}
+
+	if len(media) == 0 {
+		return nil, nil, true
+	}
+
+	return media, cover, true
+}
+
+// storedPostMedia returns the attachments of a post by URL, including a legacy image
+// that has no attachment row
+func storedPostMedia(post *model.Post) map[string]model.PostMedia {
+	stored := make(map[string]model.PostMedia)
+	if post == nil {
+		return stored
+	}
+
+	for _, attachment := range post.Media {
+		stored[attachment.URL] = attachment
+	}
+	if post.Image != nil && *post.Image != "" {
+		if _, ok := stored[*post.Image]; !ok {
+			stored[*post.Image] = model.PostMedia{URL: *post.Image, Type: model.MediaTypeImage, Status: model.MediaStatusReady}
+		}
+	}
+	return stored
+}
+
+// applyVideoUpload fills a video attachment from its upload. Videos that are still
+// being transcoded are attached in the processing state and updated once done.
+func (pc *PostController) applyVideoUpload(c *gin.Context, userID uuid.UUID, upload *model.Upload, attachment *model.PostMedia) bool {
+	if upload == nil || upload.Kind != model.UploadKindVideo || upload.UserID != userID {
+		util.RespondWithError(c, http.StatusBadRequest, "Videos must be uploaded through the video uploads endpoint")
+		return false
+	}
+
+	attachment.Type = model.MediaTypeVideo
+	attachment.Blurhash = nil
+
+	switch upload.Status {
+	case model.UploadStatusProcessing, model.UploadStatusTranscoding:
+		attachment.Status = model.MediaStatusProcessing
+		attachment.Width = nil
+		attachment.Height = nil
+	case model.UploadStatusReady:
+		attachment.Width = upload.Width
+		attachment.Height = upload.Height
+		attachment.Duration = upload.Duration
+		if poster, ok := upload.Variants["poster"]; ok {
+			posterURL := pc.store.URL(poster)
+			attachment.PosterURL = &posterURL
+		}
+	case model.UploadStatusFailed:
+		util.RespondWithError(c, http.StatusBadRequest, "Video could not be processed")
+		return false
+	default:
+		util.RespondWithError(c, http.StatusBadRequest, "Video upload is not complete")
+		return false
+	}
+
+	return true
+}
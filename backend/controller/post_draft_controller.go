@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"socialnet/middleware"
+	"socialnet/model"
+	"socialnet/repository"
+	"socialnet/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetDrafts returns the current user's drafts and scheduled posts
+func (pc *PostController) GetDrafts(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	var filter model.Pagination
+	if !middleware.BindQuery(c, &filter) {
+		return
+	}
+
+	drafts, err := pc.repo.Post.FindDrafts(userID, filter)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch drafts")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Drafts retrieved successfully", drafts)
+}
+
+// CreateDraft saves a new draft, scheduling it when a publish time is given
+func (pc *PostController) CreateDraft(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	var input model.PostDraft
+	if !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	post := model.Post{
+		ID:     uuid.New(),
+		UserID: userID,
+	}
+	if !pc.applyDraft(c, userID, &post, &input) {
+		return
+	}
+
+	if err := pc.repo.Post.Create(&post); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to create draft")
+		return
+	}
+
+	draft, err := pc.repo.Post.FindDraft(post.ID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch draft")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusCreated, "Draft created successfully", draft)
+}
+
+// UpdateDraft replaces the content and schedule of a draft
+func (pc *PostController) UpdateDraft(c *gin.Context) {
+	post, userID, ok := pc.findOwnedDraft(c)
+	if !ok {
+		return
+	}
+
+	var input model.PostDraft
+	if !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	if !pc.applyDraft(c, userID, post, &input) {
+		return
+	}
+
+	err := pc.repo.Post.Update(post)
+	if errors.Is(err, repository.ErrPostAlreadyPublished) {
+		util.RespondWithError(c, http.StatusConflict, "Draft has already been published")
+		return
+	}
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to update draft")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Draft updated successfully", post)
+}
+
+// DeleteDraft deletes a draft or cancels a scheduled post
+func (pc *PostController) DeleteDraft(c *gin.Context) {
+	post, _, ok := pc.findOwnedDraft(c)
+	if !ok {
+		return
+	}
+
+	if err := pc.repo.Post.Delete(post.ID); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to delete draft")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Draft deleted successfully", nil)
+}
+
+// PublishDraft publishes a draft or scheduled post immediately
+func (pc *PostController) PublishDraft(c *gin.Context) {
+	post, userID, ok := pc.findOwnedDraft(c)
+	if !ok {
+		return
+	}
+
+	if !isPublishable(post.Content, post.Media) {
+		util.RespondWithError(c, http.StatusBadRequest, "A post needs content or media to be published")
+		return
+	}
+
+	published, err := pc.repo.Post.Publish(post.ID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to publish draft")
+		return
+	}
+	if !published {
+		util.RespondWithError(c, http.StatusConflict, "Draft has already been published")
+		return
+	}
+
+	publishedPost, err := pc.repo.Post.FindByID(post.ID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch post")
+		return
+	}
+
+	fillPostViewerInfo(pc.repo, &userID, publishedPost)
+
+	util.RespondWithSuccess(c, http.StatusOK, "Draft published successfully", publishedPost)
+}
+
+// findOwnedDraft loads the draft named in the URL and checks that it belongs to the
+// current user
+func (pc *PostController) findOwnedDraft(c *gin.Context) (*model.Post, uuid.UUID, bool) {
+	id, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return nil, uuid.Nil, false
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return nil, uuid.Nil, false
+	}
+
+	post, err := pc.repo.Post.FindDraft(id)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Draft not found")
+		return nil, uuid.Nil, false
+	}
+
+	if !middleware.CheckResourceOwnership(c, post.UserID, userID) {
+		return nil, uuid.Nil, false
+	}
+
+	return post, userID, true
+}
+
+// applyDraft copies draft input onto a post, validating the schedule if one is set
+func (pc *PostController) applyDraft(c *gin.Context, userID uuid.UUID, post *model.Post, input *model.PostDraft) bool {
+	media, image, ok := pc.buildPostMedia(c, userID, post, input.Media, input.Image)
+	if !ok {
+		return false
+	}
+
+	post.Content = input.Content
+	post.Image = image
+	post.Media = media
+	post.Status = model.PostStatusDraft
+	post.PublishAt = nil
+
+	if input.PublishAt != nil {
+		if !input.PublishAt.After(time.Now()) {
+			util.RespondWithError(c, http.StatusBadRequest, "Publish time must be in the future")
+			return false
+		}
+		if !isPublishable(post.Content, post.Media) {
+			util.RespondWithError(c, http.StatusBadRequest, "A post needs content or media to be scheduled")
+			return false
+		}
+
+		publishAt := input.PublishAt.UTC()
+		post.Status = model.PostStatusScheduled
+		post.PublishAt = &publishAt
+	}
+
+	return true
+}
+
+// isPublishable reports whether a post has anything to show
+func isPublishable(content string, media []model.PostMedia) bool {
+	return strings.TrimSpace(content) != "" || len(media) > 0
+}
@@ -1,7 +1,9 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
 	"socialnet/config"
 	"socialnet/middleware"
@@ -107,6 +109,115 @@ func (pic *PostInteractionController) UnlikePost(c *gin.Context) {
 	})
 }
 
+// ReactToPost sets the current user's reaction to a post, replacing any earlier one
+func (pic *PostInteractionController) ReactToPost(c *gin.Context) {
+	postID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	var input model.ReactionInput
+	if !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	if !model.IsValidReaction(input.Reaction) {
+		util.RespondWithError(c, http.StatusBadRequest, "Reaction must be one of "+strings.Join(model.StandardReactions, ", ")+" or a single emoji")
+		return
+	}
+
+	// Check if post exists
+	_, err = pic.repo.Post.FindByID(postID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	post, err := pic.repo.Post.React(userID, postID, input.Reaction)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to react to post")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Reaction saved successfully", gin.H{
+		"likes":     post.LikesCount,
+		"reactions": post.Reactions,
+		"reaction":  input.Reaction,
+	})
+}
+
+// RemoveReaction removes the current user's reaction from a post
+func (pic *PostInteractionController) RemoveReaction(c *gin.Context) {
+	postID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	// Check if reaction exists
+	isLiked, err := pic.repo.Post.IsLiked(userID, postID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, util.ErrorMessages.DatabaseError)
+		return
+	}
+
+	if !isLiked {
+		util.RespondWithError(c, http.StatusNotFound, "No reaction to remove")
+		return
+	}
+
+	post, err := pic.repo.Post.Unreact(userID, postID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to remove reaction")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Reaction removed successfully", gin.H{
+		"likes":     post.LikesCount,
+		"reactions": post.Reactions,
+	})
+}
+
+// GetReactions lists who reacted to a post, optionally only with a given reaction
+func (pic *PostInteractionController) GetReactions(c *gin.Context) {
+	postID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	var filter model.ReactionFilter
+	if !middleware.BindQuery(c, &filter) {
+		return
+	}
+
+	// Check if post exists
+	_, err = pic.repo.Post.FindByID(postID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	reactions, meta, err := pic.repo.Post.FindReactions(postID, filter)
+	if err != nil {
+		util.RespondWithListError(c, err, "Failed to fetch reactions")
+		return
+	}
+
+	util.RespondWithPagination(c, http.StatusOK, "Reactions retrieved successfully", reactions, meta)
+}
+
 // SharePost shares an existing post
 func (pic *PostInteractionController) SharePost(c *gin.Context) {
 	postID, err := middleware.ParseUUIDParam(c, "id")
@@ -132,12 +243,131 @@ func (pic *PostInteractionController) SharePost(c *gin.Context) {
 		return
 	}
 
+	content := strings.TrimSpace(input.Content)
+	shareType := input.Type
+	if shareType == "" {
+		shareType = model.ShareTypeRepost
+		if content != "" {
+			shareType = model.ShareTypeQuote
+		}
+	}
+
+	if shareType == model.ShareTypeQuote && content == "" {
+		util.RespondWithError(c, http.StatusBadRequest, "A quote needs content")
+		return
+	}
+	if shareType == model.ShareTypeRepost && content != "" {
+		util.RespondWithError(c, http.StatusBadRequest, "A repost can't have content; quote the post instead")
+		return
+	}
+
 	// Share the post
-	sharedPost, err := pic.repo.Post.Share(userID, postID, input.Content)
+	sharedPost, err := pic.repo.Post.Share(userID, postID, shareType, content)
+	if errors.Is(err, repository.ErrAlreadyReposted) {
+		util.RespondWithError(c, http.StatusConflict, "Post already reposted")
+		return
+	}
 	if err != nil {
 		util.RespondWithError(c, http.StatusInternalServerError, "Failed to share post")
 		return
 	}
 
+	fillPostViewerInfo(pic.repo, &userID, sharedPost)
+
 	util.RespondWithSuccess(c, http.StatusCreated, "Post shared successfully", sharedPost)
 }
+
+// UnsharePost undoes the current user's repost of a post. Quotes are deleted like any
+// other post.
+func (pic *PostInteractionController) UnsharePost(c *gin.Context) {
+	postID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	err = pic.repo.Post.Unrepost(userID, postID)
+	if errors.Is(err, repository.ErrNotReposted) {
+		util.RespondWithError(c, http.StatusNotFound, "Post not reposted")
+		return
+	}
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to undo repost")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Repost removed successfully", nil)
+}
+
+// GetReposters lists the users who reposted a post
+func (pic *PostInteractionController) GetReposters(c *gin.Context) {
+	postID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	var filter model.Pagination
+	if !middleware.BindQuery(c, &filter) {
+		return
+	}
+
+	// Check if post exists
+	_, err = pic.repo.Post.FindByID(postID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	users, meta, err := pic.repo.Post.FindReposters(postID, filter)
+	if err != nil {
+		util.RespondWithListError(c, err, "Failed to fetch reposts")
+		return
+	}
+
+	if users, err = pic.repo.User.FillFollowingInfo(middleware.GetOptionalUserID(c), users); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch following info")
+		return
+	}
+
+	util.RespondWithPagination(c, http.StatusOK, "Reposts retrieved successfully", users, meta)
+}
+
+// GetQuotes lists the posts quoting a post
+func (pic *PostInteractionController) GetQuotes(c *gin.Context) {
+	postID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	var filter model.Pagination
+	if !middleware.BindQuery(c, &filter) {
+		return
+	}
+
+	// Check if post exists
+	_, err = pic.repo.Post.FindByID(postID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	posts, meta, err := pic.repo.Post.FindQuotes(postID, filter)
+	if err != nil {
+		util.RespondWithListError(c, err, "Failed to fetch quotes")
+		return
+	}
+
+	if posts, err = fillViewerInfo(pic.repo, middleware.GetOptionalUserID(c), posts); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch like info")
+		return
+	}
+
+	util.RespondWithPagination(c, http.StatusOK, "Quotes retrieved successfully", posts, meta)
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"net/http"
+
+	"socialnet/middleware"
+	"socialnet/model"
+	"socialnet/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetThread returns a post in its conversation: the posts it replies to and a page of
+// the replies below it. With mode=self only the chain of posts by the post's author is
+// returned.
+func (pc *PostController) GetThread(c *gin.Context) {
+	id, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid post ID format")
+		return
+	}
+
+	var filter model.ThreadFilter
+	if !middleware.BindQuery(c, &filter) {
+		return
+	}
+
+	post, err := pc.repo.Post.FindByID(id)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	ancestors, err := pc.repo.Post.FindAncestors(post, filter.Mode == model.ThreadModeSelf)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch thread")
+		return
+	}
+
+	replies, err := pc.repo.Post.FindReplies(post, filter)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch thread")
+		return
+	}
+
+	// Fill viewer info for the whole thread at once
+	currentUserID := middleware.GetOptionalUserID(c)
+	posts := make([]model.Post, 0, len(ancestors)+1+len(replies))
+	posts = append(posts, ancestors...)
+	posts = append(posts, *post)
+	posts = append(posts, replies...)
+	if posts, err = fillViewerInfo(pc.repo, currentUserID, posts); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch like info")
+		return
+	}
+
+	ancestors = posts[:len(ancestors)]
+	post = &posts[len(ancestors)]
+	replies = posts[len(ancestors)+1:]
+
+	util.RespondWithSuccess(c, http.StatusOK, "Thread retrieved successfully", model.Thread{
+		Ancestors: ancestors,
+		Post:      post,
+		Replies:   model.BuildReplyTree(post.ID, replies),
+	})
+}
@@ -60,19 +60,19 @@ func (sc *SearchController) SearchPosts(c *gin.Context) {
 	currentUserID := middleware.GetOptionalUserID(c)
 
 	// Search posts in database
-	posts, err := sc.repo.Post.SearchPosts(filter.Query, filter.Pagination)
+	posts, meta, err := sc.repo.Post.SearchPosts(filter.Query, filter.Pagination)
 	if err != nil {
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to search posts")
+		util.RespondWithListError(c, err, "Failed to search posts")
 		return
 	}
 
 	// If user is authenticated, check if posts are liked
-	if posts, err = sc.repo.Post.FillLikeInfo(currentUserID, posts); err != nil {
+	if posts, err = fillViewerInfo(sc.repo, currentUserID, posts); err != nil {
 		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch like info")
 		return
 	}
 
-	util.RespondWithSuccess(c, http.StatusOK, "Posts found", posts)
+	util.RespondWithPagination(c, http.StatusOK, "Posts found", posts, meta)
 }
 
 // Search performs a combined search across users and posts
@@ -97,13 +97,13 @@ func (sc *SearchController) Search(c *gin.Context) {
 	}
 
 	// Search posts
-	posts, err := sc.repo.Post.SearchPosts(filter.Query, filter.Pagination)
+	posts, _, err := sc.repo.Post.SearchPosts(filter.Query, filter.Pagination)
 	if err != nil {
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to search posts")
+		util.RespondWithListError(c, err, "Failed to search posts")
 		return
 	}
 
-	if posts, err = sc.repo.Post.FillLikeInfo(currentUserID, posts); err != nil {
+	if posts, err = fillViewerInfo(sc.repo, currentUserID, posts); err != nil {
 		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch like info")
 		return
 	}
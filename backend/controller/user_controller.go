@@ -1,10 +1,17 @@
 package controller
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"gorm.io/gorm"
+	"image"
+	"io"
+	"log"
 	"net/http"
+	"socialnet/storage"
 	"socialnet/util"
+	"time"
 
 	"socialnet/config"
 	"socialnet/middleware"
@@ -17,23 +24,24 @@ import (
 
 // UserController handles user-related requests
 type UserController struct {
-	repo *repository.Repository
-	cfg  *config.Config
+	repo  *repository.Repository
+	store storage.Storage
+	cfg   *config.Config
 }
 
 // NewUserController creates a new UserController
-func NewUserController(repo *repository.Repository, cfg *config.Config) *UserController {
+func NewUserController(repo *repository.Repository, store storage.Storage, cfg *config.Config) *UserController {
 	return &UserController{
-		repo: repo,
-		cfg:  cfg,
+		repo:  repo,
+		store: store,
+		cfg:   cfg,
 	}
 }
 
 // GetUsers returns a list of users
 func (uc *UserController) GetUsers(c *gin.Context) {
 	var filter model.UserFilter
-	if err := c.ShouldBindQuery(&filter); err != nil {
-		util.RespondWithError(c, http.StatusBadRequest, err.Error())
+	if !middleware.BindQuery(c, &filter) {
 		return
 	}
 
@@ -157,6 +165,22 @@ func (uc *UserController) GetCurrentUser(c *gin.Context) {
 	util.RespondWithSuccess(c, http.StatusOK, "User found", user)
 }
 
+// GetStorageUsage returns the storage used by the authenticated user and their quotas
+func (uc *UserController) GetStorageUsage(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	usage, err := storageUsage(uc.repo, uc.cfg, userID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch storage usage")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "Storage usage retrieved successfully", usage)
+}
+
 // UpdateUser updates a user's profile
 func (uc *UserController) UpdateUser(c *gin.Context) {
 	idStr := c.Param("id")
@@ -193,6 +217,25 @@ func (uc *UserController) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// Move the upload references of a changed avatar or cover so that the new image is
+	// kept and the old one can be garbage collected. An image sent back unchanged keeps
+	// its references and isn't checked again, as it may predate the uploads registry.
+	user.ProfileUploads = make(map[model.UploadRefType][]uuid.UUID)
+	if input.Avatar != nil && !equalURL(input.Avatar, user.Avatar) {
+		uploads, ok := uc.profileUploads(c, user.ID, *input.Avatar, "Avatar", "PUT /users/me/avatar")
+		if !ok {
+			return
+		}
+		user.ProfileUploads[model.UploadRefAvatar] = uploads
+	}
+	if input.Cover != nil && !equalURL(input.Cover, user.Cover) {
+		uploads, ok := uc.profileUploads(c, user.ID, *input.Cover, "Cover", "PUT /users/me/cover")
+		if !ok {
+			return
+		}
+		user.ProfileUploads[model.UploadRefCover] = uploads
+	}
+
 	// Update fields if provided
 	if input.Name != nil {
 		user.Name = *input.Name
@@ -228,6 +271,161 @@ func (uc *UserController) UpdateUser(c *gin.Context) {
 	util.RespondWithSuccess(c, http.StatusOK, "success", user)
 }
 
+// UpdateAvatar crops an uploaded image into square avatar variants and sets it as the
+// current user's avatar
+func (uc *UserController) UpdateAvatar(c *gin.Context) {
+	uc.updateProfileImage(c, model.UploadRefAvatar, util.AvatarVariants)
+}
+
+// UpdateCover crops an uploaded image into banner variants and sets it as the current
+// user's cover
+func (uc *UserController) UpdateCover(c *gin.Context) {
+	uc.updateProfileImage(c, model.UploadRefCover, util.CoverVariants)
+}
+
+// updateProfileImage produces the cropped variants of an upload and points the avatar
+// or cover of the current user at the largest one
+func (uc *UserController) updateProfileImage(c *gin.Context, refType model.UploadRefType, specs []util.CropVariantSpec) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	var input model.ProfileImageUpdate
+	if !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	source, err := uc.repo.Upload.FindByID(input.UploadID)
+	if err != nil || source.UserID != userID {
+		util.RespondWithError(c, http.StatusNotFound, "Upload not found")
+		return
+	}
+
+	if source.Kind != model.UploadKindImage || source.Status != model.UploadStatusReady {
+		util.RespondWithError(c, http.StatusBadRequest, "Upload is not a usable image")
+		return
+	}
+
+	data, err := uc.readUpload(c, source.Key)
+	if err != nil {
+		log.Printf("Failed to read upload %s: %v", source.ID, err)
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to read upload")
+		return
+	}
+
+	rect := image.Rect(input.X, input.Y, input.X+input.Width, input.Y+input.Height)
+	variants, err := util.CropImage(data, rect, specs)
+	if errors.Is(err, util.ErrInvalidCrop) {
+		util.RespondWithError(c, http.StatusBadRequest, "Crop rectangle must lie within the image")
+		return
+	}
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid image file")
+		return
+	}
+
+	var totalSize int64
+	for _, variant := range variants {
+		totalSize += int64(len(variant.Data))
+	}
+	if !reserveUploadQuota(c, uc.repo, uc.cfg, userID, totalSize) {
+		return
+	}
+
+	baseName := fmt.Sprintf("%s-%s-%s", time.Now().Format("20060102"), uuid.New().String(), refType)
+
+	var storedKeys []string
+	var storedSize int64
+	variantKeys := make(map[string]string, len(variants))
+	variantURLs := make(map[string]string, len(variants))
+	for _, variant := range variants {
+		fileKey := fmt.Sprintf("%s%s-%s%s", uploadKeyPrefix, baseName, variant.Name, variant.Ext)
+		if err := uc.store.Put(c, fileKey, bytes.NewReader(variant.Data), int64(len(variant.Data)), variant.ContentType); err != nil {
+			log.Printf("Failed to store %s variant: %v", refType, err)
+			uc.deleteObjects(c, storedKeys)
+			releaseUploadQuota(uc.repo, userID, totalSize)
+			util.RespondWithError(c, http.StatusInternalServerError, "Failed to store image")
+			return
+		}
+
+		storedKeys = append(storedKeys, fileKey)
+		storedSize += int64(len(variant.Data))
+		variantKeys[variant.Name] = fileKey
+		variantURLs[variant.Name] = uc.store.URL(fileKey)
+	}
+
+	largest := variants[len(variants)-1]
+	upload := model.Upload{
+		UserID:      userID,
+		Key:         variantKeys[largest.Name],
+		Kind:        model.UploadKindImage,
+		ContentType: largest.ContentType,
+		Size:        storedSize,
+		Status:      model.UploadStatusReady,
+		Variants:    variantKeys,
+	}
+
+	if err := uc.repo.Upload.Create(&upload); err != nil {
+		uc.deleteObjects(c, storedKeys)
+		releaseUploadQuota(uc.repo, userID, totalSize)
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to store image")
+		return
+	}
+
+	user, err := uc.repo.User.FindByID(userID)
+	if err != nil {
+		util.RespondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	imageURL := uc.store.URL(upload.Key)
+	if refType == model.UploadRefAvatar {
+		user.Avatar = &imageURL
+	} else {
+		user.Cover = &imageURL
+	}
+
+	// The new variants replace the previous image, which is left to the garbage collector
+	// together with the uncropped source
+	user.ProfileUploads = map[model.UploadRefType][]uuid.UUID{refType: {upload.ID}}
+
+	if err := uc.repo.User.Update(user); err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusOK, "success", gin.H{
+		"user":     user,
+		"variants": variantURLs,
+	})
+}
+
+// readUpload reads a stored upload into memory
+func (uc *UserController) readUpload(c *gin.Context, key string) ([]byte, error) {
+	info, err := uc.store.Stat(c, key)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := uc.store.GetRange(c, key, 0, info.Size)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	return io.ReadAll(io.LimitReader(object, maxUploadSize))
+}
+
+// deleteObjects removes already stored objects after a partially failed update
+func (uc *UserController) deleteObjects(c *gin.Context, keys []string) {
+	for _, key := range keys {
+		if err := uc.store.Delete(c, key); err != nil {
+			log.Printf("Failed to clean up stored object %s: %v", key, err)
+		}
+	}
+}
+
 // FollowUser creates a follow relationship between users
 func (uc *UserController) FollowUser(c *gin.Context) {
 	followingIDStr := c.Param("id")
@@ -277,6 +475,12 @@ func (uc *UserController) FollowUser(c *gin.Context) {
 		return
 	}
 
+	// Show the account's recent posts right away; a failure only leaves the timeline
+	// short until it is rebuilt
+	if err := uc.repo.Timeline.Backfill(followerID, followingID, uc.cfg.Timeline.BackfillSize, uc.cfg.Timeline.PullThreshold); err != nil {
+		log.Printf("Failed to backfill timeline of user %s: %v", followerID, err)
+	}
+
 	util.RespondWithSuccess(c, http.StatusCreated, "Successfully followed user", nil)
 }
 
@@ -316,6 +520,10 @@ func (uc *UserController) UnfollowUser(c *gin.Context) {
 		return
 	}
 
+	if err := uc.repo.Timeline.Purge(followerID, followingID); err != nil {
+		log.Printf("Failed to purge timeline of user %s: %v", followerID, err)
+	}
+
 	util.RespondWithSuccess(c, http.StatusOK, "success", gin.H{"message": "Successfully unfollowed user"})
 }
 
@@ -329,15 +537,14 @@ func (uc *UserController) GetFollowers(c *gin.Context) {
 	}
 
 	var filter model.FollowFilter
-	if err := c.ShouldBindQuery(&filter); err != nil {
-		util.RespondWithError(c, http.StatusBadRequest, err.Error())
+	if !middleware.BindQuery(c, &filter) {
 		return
 	}
 
 	// Query followers from database
-	followers, err := uc.repo.User.GetFollowers(userID, filter)
+	followers, meta, err := uc.repo.User.GetFollowers(userID, filter)
 	if err != nil {
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch followers")
+		util.RespondWithListError(c, err, "Failed to fetch followers")
 		return
 	}
 
@@ -351,7 +558,7 @@ func (uc *UserController) GetFollowers(c *gin.Context) {
 		}
 	}
 
-	util.RespondWithSuccess(c, http.StatusOK, "success", followers)
+	util.RespondWithPagination(c, http.StatusOK, "success", followers, meta)
 }
 
 // GetFollowing returns users that the specified user follows
@@ -364,15 +571,14 @@ func (uc *UserController) GetFollowing(c *gin.Context) {
 	}
 
 	var filter model.FollowFilter
-	if err := c.ShouldBindQuery(&filter); err != nil {
-		util.RespondWithError(c, http.StatusBadRequest, err.Error())
+	if !middleware.BindQuery(c, &filter) {
 		return
 	}
 
 	// Query following from database
-	following, err := uc.repo.User.GetFollowing(userID, filter)
+	following, meta, err := uc.repo.User.GetFollowing(userID, filter)
 	if err != nil {
-		util.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch following")
+		util.RespondWithListError(c, err, "Failed to fetch following")
 		return
 	}
 
@@ -386,7 +592,7 @@ func (uc *UserController) GetFollowing(c *gin.Context) {
 		}
 	}
 
-	util.RespondWithSuccess(c, http.StatusOK, "success", following)
+	util.RespondWithPagination(c, http.StatusOK, "success", following, meta)
 }
 
 // GetSuggestedUsers returns users that might interest the user
@@ -400,8 +606,7 @@ func (uc *UserController) GetSuggestedUsers(c *gin.Context) {
 	userID, _ := uuid.Parse(userIDStr)
 
 	var filter model.Pagination
-	if err := c.ShouldBindQuery(&filter); err != nil {
-		util.RespondWithError(c, http.StatusBadRequest, err.Error())
+	if !middleware.BindQuery(c, &filter) {
 		return
 	}
 
@@ -443,3 +648,37 @@ func (uc *UserController) SaveFCMToken(c *gin.Context) {
 	}
 	util.RespondWithSuccess(c, http.StatusOK, "success", nil)
 }
+
+// equalURL reports whether a requested profile image URL is the one already stored
+func equalURL(requested, stored *string) bool {
+	return stored != nil && *requested == *stored
+}
+
+// profileUploads returns the upload an avatar or cover URL points at. A URL into the
+// uploads bucket must name a ready upload of the user, and other URLs are only accepted
+// while foreign profile URLs are allowed; otherwise it responds with an error.
+func (uc *UserController) profileUploads(c *gin.Context, userID uuid.UUID, fileURL, field, endpoint string) ([]uuid.UUID, bool) {
+	if fileURL == "" {
+		return nil, true
+	}
+
+	key, ok := uploadKeyFromURL(uc.store, fileURL)
+	if !ok {
+		if uc.cfg.Storage.RejectForeignProfileURLs {
+			util.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("%s must be set through %s", field, endpoint))
+			return nil, false
+		}
+		return nil, true
+	}
+
+	upload, err := uc.repo.Upload.FindByKey(key)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to check uploads")
+		return nil, false
+	}
+	if err != nil || upload.UserID != userID || upload.Kind != model.UploadKindImage || upload.Status != model.UploadStatusReady {
+		util.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("%s must be one of your own uploaded images", field))
+		return nil, false
+	}
+	return []uuid.UUID{upload.ID}, true
+}
@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"socialnet/middleware"
+	"socialnet/model"
+	"socialnet/util"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// uploadOffsetHeader carries the byte offset of a chunk and the number of bytes received
+const uploadOffsetHeader = "Upload-Offset"
+
+// StartVideoUpload registers a resumable video upload. The client then sends the file
+// in chunks of the returned size to UploadVideoChunk.
+func (fc *FileController) StartVideoUpload(c *gin.Context) {
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return
+	}
+
+	var input model.VideoUploadInit
+	if !middleware.BindJSON(c, &input) {
+		return
+	}
+
+	if input.Size > fc.cfg.Video.MaxSize {
+		util.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("Video too large (max %dMB)", fc.cfg.Video.MaxSize/(1024*1024)))
+		return
+	}
+
+	contentType, found := util.VideoContentType(filepath.Ext(input.Filename))
+	if !found {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid video type (only .mp4, .webm and .mov)")
+		return
+	}
+
+	if input.ContentType != contentType {
+		util.RespondWithError(c, http.StatusBadRequest, "Content type does not match file extension")
+		return
+	}
+
+	if !fc.reserveUploadQuota(c, userID, input.Size) {
+		return
+	}
+
+	// Videos are always transcoded to MP4, so the final URL is known upfront and can be
+	// attached to a post while the video is still processing
+	fileName := fmt.Sprintf("%s-%s.mp4", time.Now().Format("20060102"), uuid.New().String())
+	upload := model.Upload{
+		UserID:      userID,
+		Key:         uploadKeyPrefix + fileName,
+		Kind:        model.UploadKindVideo,
+		ContentType: contentType,
+		Size:        input.Size,
+		Status:      model.UploadStatusUploading,
+		ChunkSize:   fc.cfg.Video.ChunkSize,
+	}
+
+	if err := fc.repo.Upload.Create(&upload); err != nil {
+		fc.releaseUploadQuota(userID, input.Size)
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+
+	util.RespondWithSuccess(c, http.StatusCreated, "Video upload created successfully", fc.videoUploadResponse(&upload))
+}
+
+// GetVideoUpload returns the progress of a video upload, which clients use to resume an
+// interrupted transfer and to poll for the end of processing
+func (fc *FileController) GetVideoUpload(c *gin.Context) {
+	upload, ok := fc.findOwnedVideoUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header(uploadOffsetHeader, strconv.FormatInt(upload.Received, 10))
+	util.RespondWithSuccess(c, http.StatusOK, "Video upload retrieved successfully", fc.videoUploadResponse(upload))
+}
+
+// UploadVideoChunk stores the chunk of a video upload starting at the offset given in
+// the Upload-Offset header. Chunks must be sent in order and, except for the last one,
+// be exactly the chunk size of the upload. Once the last chunk arrives the video is
+// queued for transcoding.
+func (fc *FileController) UploadVideoChunk(c *gin.Context) {
+	upload, ok := fc.findOwnedVideoUpload(c)
+	if !ok {
+		return
+	}
+
+	if upload.Status != model.UploadStatusUploading {
+		util.RespondWithError(c, http.StatusConflict, "Upload is already complete")
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid Upload-Offset header")
+		return
+	}
+
+	// Tell the client where to resume instead of accepting an out of order chunk
+	if offset != upload.Received {
+		c.Header(uploadOffsetHeader, strconv.FormatInt(upload.Received, 10))
+		util.RespondWithError(c, http.StatusConflict, fmt.Sprintf("Expected chunk at offset %d", upload.Received))
+		return
+	}
+
+	length := min(upload.ChunkSize, upload.Size-offset)
+	if c.Request.ContentLength != length {
+		util.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("Chunk must be %d bytes", length))
+		return
+	}
+
+	chunk, err := io.ReadAll(io.LimitReader(c.Request.Body, length+1))
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Failed to read chunk")
+		return
+	}
+	if int64(len(chunk)) != length {
+		util.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("Chunk must be %d bytes", length))
+		return
+	}
+
+	// The container is identified from the first chunk so that unsupported files are
+	// rejected before the rest is transferred
+	if offset == 0 {
+		detected, err := util.DetectVideo(chunk, "")
+		if err == nil && detected.ContentType != upload.ContentType {
+			err = util.ErrExtensionMismatch
+		}
+		if err != nil {
+			if err := fc.repo.Upload.Delete(upload.ID); err != nil {
+				log.Printf("Failed to delete rejected upload %s: %v", upload.ID, err)
+			}
+			util.RespondWithError(c, http.StatusBadRequest, invalidVideoMessage(err))
+			return
+		}
+	}
+
+	if err := fc.store.Put(c, upload.ChunkKey(offset), bytes.NewReader(chunk), length, "application/octet-stream"); err != nil {
+		log.Printf("Failed to store upload chunk: %v", err)
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to store chunk")
+		return
+	}
+
+	appended, err := fc.repo.Upload.AppendChunk(upload, offset, length)
+	if err != nil {
+		util.RespondWithError(c, http.StatusInternalServerError, "Failed to store chunk")
+		return
+	}
+	if !appended {
+		util.RespondWithError(c, http.StatusConflict, "Chunk was already received")
+		return
+	}
+
+	c.Header(uploadOffsetHeader, strconv.FormatInt(upload.Received, 10))
+	util.RespondWithSuccess(c, http.StatusOK, "Chunk uploaded successfully", fc.videoUploadResponse(upload))
+}
+
+// findOwnedVideoUpload loads the video upload named in the URL and checks that it
+// belongs to the current user
+func (fc *FileController) findOwnedVideoUpload(c *gin.Context) (*model.Upload, bool) {
+	uploadID, err := middleware.ParseUUIDParam(c, "id")
+	if err != nil {
+		util.RespondWithError(c, http.StatusBadRequest, "Invalid upload ID format")
+		return nil, false
+	}
+
+	userID, ok := middleware.RequireAuthentication(c)
+	if !ok {
+		return nil, false
+	}
+
+	upload, err := fc.repo.Upload.FindByID(uploadID)
+	if err != nil || upload.Kind != model.UploadKindVideo {
+		util.RespondWithError(c, http.StatusNotFound, "Upload not found")
+		return nil, false
+	}
+
+	if !middleware.CheckResourceOwnership(c, upload.UserID, userID) {
+		return nil, false
+	}
+
+	return upload, true
+}
+
+// videoUploadResponse describes the state of a video upload to the client
+func (fc *FileController) videoUploadResponse(upload *model.Upload) gin.H {
+	response := gin.H{
+		"id":        upload.ID,
+		"url":       fc.store.URL(upload.Key),
+		"status":    upload.Status,
+		"size":      upload.Size,
+		"offset":    upload.Received,
+		"chunkSize": upload.ChunkSize,
+	}
+
+	if poster, ok := upload.Variants["poster"]; ok {
+		response["posterUrl"] = fc.store.URL(poster)
+	}
+	if upload.Duration != nil {
+		response["duration"] = *upload.Duration
+	}
+	if upload.Width != nil && upload.Height != nil {
+		response["width"] = *upload.Width
+		response["height"] = *upload.Height
+	}
+	if upload.Error != nil {
+		response["error"] = *upload.Error
+	}
+
+	return response
+}
+
+// invalidVideoMessage returns the client facing message for a video validation error
+func invalidVideoMessage(err error) string {
+	if errors.Is(err, util.ErrExtensionMismatch) {
+		return "File extension does not match its content"
+	}
+	return "Invalid video type (only .mp4, .webm and .mov)"
+}
@@ -1,10 +1,12 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
 	"socialnet/config"
 	"socialnet/model"
 	"socialnet/util"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -24,18 +26,206 @@ func RunMigrations(db *gorm.DB) error {
 	// Enable the uuid-ossp extension
 	db.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\";")
 
+	// Timeline entries made before they carried their post's creation time get it copied
+	// once the column exists
+	dateTimelineEntries := !db.Migrator().HasColumn(&model.TimelineEntry{}, "created_at")
+
 	// Auto-migrate models
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		&model.User{},
 		&model.Follow{},
 		&model.Post{},
+		&model.PostMedia{},
 		&model.Like{},
 		&model.Comment{},
+		&model.CommentLike{},
 		&model.Message{},
 		&model.Conversation{},
 		&model.Notification{},
 		&model.FCMToken{},
+		&model.Upload{},
+		&model.UploadReference{},
+		&model.UploadObject{},
+		&model.UploadDailyUsage{},
+		&model.UploadStorageUsage{},
+		&model.PostRevision{},
+		&model.CommentRevision{},
+		&model.Poll{},
+		&model.PollOption{},
+		&model.PollVote{},
+		&model.BookmarkCollection{},
+		&model.Bookmark{},
+		&model.TimelineEntry{},
+		&model.FanoutJob{},
+		&model.EngagementEvent{},
+		&model.TrendingPost{},
 	)
+	if err != nil {
+		return err
+	}
+
+	// Likes made before reactions existed count as the default reaction
+	if err := db.Exec("UPDATE posts SET reactions = jsonb_build_object(?::text, likes_count) WHERE reactions IS NULL AND likes_count > 0",
+		model.ReactionLike).Error; err != nil {
+		return err
+	}
+
+	if err := classifyShares(db); err != nil {
+		return err
+	}
+
+	if dateTimelineEntries {
+		if err := db.Exec(`UPDATE timeline_entries SET created_at = posts.created_at
+			FROM posts WHERE posts.id = timeline_entries.post_id`).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := moveUploadReferences(db); err != nil {
+		return err
+	}
+
+	if err := seedStorageUsage(db); err != nil {
+		return err
+	}
+
+	if err := seedUploadObjects(db); err != nil {
+		return err
+	}
+
+	return seedEngagementEvents(db)
+}
+
+// seedEngagementEvents records the last week of likes, comments and shares as engagement
+// events the first time the events table is created, so that trending isn't empty
+// until new activity comes in
+func seedEngagementEvents(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&model.EngagementEvent{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+
+	since := time.Now().Add(-7 * 24 * time.Hour)
+	return db.Exec(`
+		INSERT INTO engagement_events (post_id, user_id, kind, created_at)
+		SELECT post_id, user_id, ?, created_at FROM likes WHERE created_at > ?
+		UNION ALL
+		SELECT post_id, user_id, ?, created_at FROM comments WHERE created_at > ? AND deleted_at IS NULL
+		UNION ALL
+		SELECT shared_post_id, user_id, ?, created_at FROM posts
+		WHERE shared_post_id IS NOT NULL AND created_at > ? AND deleted_at IS NULL AND status = ?`,
+		model.EngagementReaction, since,
+		model.EngagementComment, since,
+		model.EngagementShare, since, model.PostStatusPublished).Error
+}
+
+// seedStorageUsage totals the size of every user's uploads the first time storage usage
+// is tracked
+func seedStorageUsage(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&model.UploadStorageUsage{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+
+	return db.Exec(`
+		INSERT INTO upload_storage_usage (user_id, bytes)
+		SELECT user_id, SUM(size) FROM uploads GROUP BY user_id
+		ON CONFLICT DO NOTHING`).Error
+}
+
+// seedUploadObjects records the object keys of the uploads made before they were kept in
+// upload_objects
+func seedUploadObjects(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&model.UploadObject{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+
+	return db.Exec(`
+		INSERT INTO upload_objects (key, upload_id)
+		SELECT key, id FROM uploads
+		UNION
+		SELECT v.value, uploads.id FROM uploads
+		CROSS JOIN LATERAL jsonb_each_text(COALESCE(uploads.variants, '{}'::jsonb)) v
+		ON CONFLICT DO NOTHING`).Error
+}
+
+// moveUploadReferences replaces the single reference uploads used to record with rows in
+// upload_references. That slot only kept the last entity an upload was attached to, so
+// the references of every post, revision, avatar and cover pointing at one of an
+// upload's objects are recovered as well.
+func moveUploadReferences(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("uploads", "referenced_id") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO upload_references (upload_id, ref_type, ref_id)
+			SELECT id, referenced_type, referenced_id FROM uploads
+			WHERE referenced_id IS NOT NULL AND referenced_type IS NOT NULL
+			ON CONFLICT DO NOTHING`).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`
+			INSERT INTO upload_references (upload_id, ref_type, ref_id)
+			SELECT DISTINCT objects.upload_id, used.ref_type, used.ref_id
+			FROM (
+				SELECT @post AS ref_type, post_media.post_id AS ref_id, post_media.url
+				FROM post_media JOIN posts ON posts.id = post_media.post_id AND posts.deleted_at IS NULL
+				UNION ALL
+				SELECT @post, post_revisions.post_id, media->>'url'
+				FROM post_revisions
+				JOIN posts ON posts.id = post_revisions.post_id AND posts.deleted_at IS NULL
+				CROSS JOIN LATERAL jsonb_array_elements(
+					CASE WHEN jsonb_typeof(post_revisions.media) = 'array' THEN post_revisions.media ELSE '[]'::jsonb END
+				) media
+				UNION ALL
+				SELECT @avatar, id, avatar FROM users WHERE avatar IS NOT NULL AND deleted_at IS NULL
+				UNION ALL
+				SELECT @cover, id, cover FROM users WHERE cover IS NOT NULL AND deleted_at IS NULL
+			) used
+			JOIN (
+				SELECT id AS upload_id, key FROM uploads
+				UNION ALL
+				SELECT uploads.id, v.value FROM uploads CROSS JOIN LATERAL jsonb_each_text(COALESCE(uploads.variants, '{}'::jsonb)) v
+			) objects ON objects.key = substring(used.url FROM '(public/uploads/[^/]+)$')
+			ON CONFLICT DO NOTHING`,
+			sql.Named("post", model.UploadRefPost),
+			sql.Named("avatar", model.UploadRefAvatar),
+			sql.Named("cover", model.UploadRefCover)).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Migrator().DropColumn("uploads", "referenced_type"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn("uploads", "referenced_id")
+	})
+}
+
+// classifyShares types shares made before reposts and quotes were told apart. Shares
+// with commentary become quotes; of the content-less shares of a post by the same user
+// the first becomes the repost and any later ones are kept as empty quotes.
+func classifyShares(db *gorm.DB) error {
+	untyped := "shared_post_id IS NOT NULL AND (share_type IS NULL OR share_type = '')"
+
+	if err := db.Exec("UPDATE posts SET share_type = ? WHERE "+untyped+" AND content <> ''", model.ShareTypeQuote).Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec(`UPDATE posts SET share_type = ? WHERE id IN (
+		SELECT DISTINCT ON (p.user_id, p.shared_post_id) p.id FROM posts p
+		WHERE p.shared_post_id IS NOT NULL AND (p.share_type IS NULL OR p.share_type = '') AND p.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM posts r WHERE r.user_id = p.user_id AND r.shared_post_id = p.shared_post_id
+			AND r.share_type = ? AND r.deleted_at IS NULL)
+		ORDER BY p.user_id, p.shared_post_id, p.created_at)`,
+		model.ShareTypeRepost, model.ShareTypeRepost).Error; err != nil {
+		return err
+	}
+
+	return db.Exec("UPDATE posts SET share_type = ? WHERE "+untyped, model.ShareTypeQuote).Error
 }
 
 // SetupApplication performs additional setup tasks
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -8,8 +9,13 @@ import (
 
 	"socialnet/config"
 	"socialnet/database"
+	"socialnet/repository"
 	"socialnet/router"
+	"socialnet/scanner"
+	"socialnet/storage"
+	"socialnet/transcoder"
 	"socialnet/util"
+	"socialnet/worker"
 )
 
 func main() {
@@ -38,12 +44,41 @@ func main() {
 		log.Printf("Warning: Failed to create email templates directory: %v", err)
 	}
 
+	// Initialize file storage backend
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// Initialize malware scanning; nil when disabled
+	fileScanner, err := scanner.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize scanner: %v", err)
+	}
+
+	repo := repository.NewRepository(db)
+
+	// Run a maintenance command instead of the server when one is given
+	if len(os.Args) > 1 {
+		runCommand(repo, cfg, os.Args[1])
+		return
+	}
+
 	// Initialize WebSocket hub
 	hub := websocket.NewHub()
 	go hub.Run()
 
+	// Start background jobs
+	go worker.NewUploadGC(repo, store, cfg).Run(context.Background())
+	go worker.NewPostScheduler(repo, cfg).Run(context.Background())
+	go worker.NewPollCloser(repo, cfg).Run(context.Background())
+	go worker.NewTimelineFanout(repo, cfg).Run(context.Background())
+	go worker.NewLiveFeed(repo, hub, cfg).Run(context.Background())
+	go worker.NewTrendingRefresher(repo, cfg).Run(context.Background())
+	go worker.NewVideoProcessor(repo, store, transcoder.New(cfg), fileScanner, cfg).Run(context.Background())
+
 	// Setup router
-	r := router.SetupRouter(db, cfg, hub)
+	r := router.SetupRouter(db, cfg, hub, store, fileScanner)
 
 	// Start server
 	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
@@ -54,3 +89,17 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// runCommand runs a maintenance command
+func runCommand(repo *repository.Repository, cfg *config.Config, command string) {
+	switch command {
+	case "rebuild-timelines":
+		rebuilt, err := worker.NewTimelineFanout(repo, cfg).Rebuild(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to rebuild timelines: %v", err)
+		}
+		log.Printf("Rebuilt %d timelines", rebuilt)
+	default:
+		log.Fatalf("Unknown command %q", command)
+	}
+}
@@ -51,6 +51,11 @@ func BindQuery(c *gin.Context, obj interface{}) bool {
 		util.RespondWithError(c, http.StatusBadRequest, err.Error())
 		return false
 	}
+
+	// Out of range page sizes are clamped rather than rejected
+	if page, ok := obj.(interface{ ClampLimit() }); ok {
+		page.ClampLimit()
+	}
 	return true
 }
 
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Bookmark represents a post a user saved privately, optionally filed in a collection
+type Bookmark struct {
+	UserID       uuid.UUID  `json:"userId" gorm:"type:uuid;primaryKey"`
+	PostID       uuid.UUID  `json:"postId" gorm:"type:uuid;primaryKey"`
+	CollectionID *uuid.UUID `json:"collectionId,omitempty" gorm:"type:uuid;index"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+
+	// Relations
+	User *User `json:"-" gorm:"foreignKey:UserID"`
+	Post *Post `json:"-" gorm:"foreignKey:PostID"`
+}
+
+// TableName specifies the table name for Bookmark model
+func (Bookmark) TableName() string {
+	return "bookmarks"
+}
+
+// BookmarkCollection represents a named group of bookmarks
+type BookmarkCollection struct {
+	ID             uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID         uuid.UUID `json:"userId" gorm:"type:uuid;not null;uniqueIndex:idx_bookmark_collection_name"`
+	Name           string    `json:"name" gorm:"size:100;not null;uniqueIndex:idx_bookmark_collection_name"`
+	BookmarksCount int       `json:"bookmarks" gorm:"-"`
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for BookmarkCollection model
+func (BookmarkCollection) TableName() string {
+	return "bookmark_collections"
+}
+
+// BeforeCreate will set a UUID rather than numeric ID.
+func (c *BookmarkCollection) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// BookmarkCreate represents data needed to bookmark a post
+type BookmarkCreate struct {
+	CollectionID *uuid.UUID `json:"collectionId,omitempty"`
+}
+
+// BookmarkCollectionInput represents data needed to create or rename a collection
+type BookmarkCollectionInput struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
@@ -73,6 +73,7 @@ type WsMessageType string
 const (
 	WsMessageTypeMessage WsMessageType = "message"
 	WsMessageTypeTyping  WsMessageType = "typing"
+	WsMessageTypeNewPost WsMessageType = "new_post"
 )
 
 type WsMessage[T any] struct {
@@ -13,8 +13,11 @@ const (
 	NotificationTypeFollow      NotificationType = "follow"
 	NotificationTypeLike        NotificationType = "like"
 	NotificationTypeComment     NotificationType = "comment"
+	NotificationTypeCommentLike NotificationType = "comment_like"
+	NotificationTypeReply       NotificationType = "reply"
 	NotificationTypeShare       NotificationType = "share"
 	NotificationTypeMessage     NotificationType = "message"
+	NotificationTypePollClosed  NotificationType = "poll_closed"
 	NotificationTypeSystemAlert NotificationType = "system_alert"
 )
 
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Poll limits
+const (
+	MinPollOptions = 2
+	MaxPollOptions = 4
+)
+
+// Poll represents a poll attached to a post. Vote counts are only exposed once the
+// viewer has voted or the poll has closed.
+type Poll struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PostID         uuid.UUID  `json:"-" gorm:"type:uuid;not null;uniqueIndex"`
+	MultipleChoice bool       `json:"multipleChoice" gorm:"not null;default:false"`
+	ExpiresAt      time.Time  `json:"expiresAt" gorm:"not null;index"`
+	ClosedAt       *time.Time `json:"-" gorm:"index"`
+	VotersCount    int        `json:"voters" gorm:"not null;default:0"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	Closed         bool       `json:"closed" gorm:"-"`
+	HasVoted       *bool      `json:"hasVoted,omitempty" gorm:"-"`
+
+	// Relations
+	Options []PollOption `json:"options" gorm:"foreignKey:PollID"`
+}
+
+// TableName specifies the table name for Poll model
+func (Poll) TableName() string {
+	return "polls"
+}
+
+// BeforeCreate will set a UUID rather than numeric ID.
+func (p *Poll) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// AfterFind flags polls that no longer accept votes
+func (p *Poll) AfterFind(tx *gorm.DB) error {
+	p.Closed = p.IsClosed(time.Now())
+	return nil
+}
+
+// IsClosed reports whether the poll stopped accepting votes at the given time
+func (p *Poll) IsClosed(now time.Time) bool {
+	return p.ClosedAt != nil || !now.Before(p.ExpiresAt)
+}
+
+// RevealResults exposes the vote count of every option
+func (p *Poll) RevealResults() {
+	for i := range p.Options {
+		votes := p.Options[i].VotesCount
+		p.Options[i].Votes = &votes
+	}
+}
+
+// PollOption represents one of the choices of a poll
+type PollOption struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PollID     uuid.UUID `json:"-" gorm:"type:uuid;not null;index"`
+	Position   int       `json:"position" gorm:"not null;default:0"`
+	Text       string    `json:"text" gorm:"size:100;not null"`
+	VotesCount int       `json:"-" gorm:"not null;default:0"`
+	Votes      *int      `json:"votes,omitempty" gorm:"-"`
+	Voted      bool      `json:"voted,omitempty" gorm:"-"`
+}
+
+// TableName specifies the table name for PollOption model
+func (PollOption) TableName() string {
+	return "poll_options"
+}
+
+// BeforeCreate will set a UUID rather than numeric ID.
+func (o *PollOption) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+// PollVote represents the ballot a user cast in a poll. A user votes once per poll;
+// multiple choice polls record every chosen option on the same ballot.
+type PollVote struct {
+	PollID    uuid.UUID   `json:"pollId" gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID   `json:"userId" gorm:"type:uuid;primaryKey;index"`
+	OptionIDs []uuid.UUID `json:"optionIds" gorm:"type:jsonb;serializer:json;not null"`
+	CreatedAt time.Time   `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for PollVote model
+func (PollVote) TableName() string {
+	return "poll_votes"
+}
+
+// PollCreate represents a poll submitted with a new post
+type PollCreate struct {
+	Options         []string `json:"options" binding:"required,min=2,max=4,dive,required,max=100"`
+	MultipleChoice  bool     `json:"multipleChoice"`
+	DurationMinutes int      `json:"durationMinutes" binding:"required,min=5,max=10080"`
+}
+
+// PollVoteInput represents the options a user votes for
+type PollVoteInput struct {
+	OptionIDs []uuid.UUID `json:"optionIds" binding:"required,min=1,max=4"`
+}
@@ -7,26 +7,71 @@ import (
 	"gorm.io/gorm"
 )
 
+// PostStatus represents the publication state of a post
+type PostStatus string
+
+const (
+	PostStatusPublished PostStatus = "published"
+	PostStatusDraft     PostStatus = "draft"
+	PostStatusScheduled PostStatus = "scheduled"
+)
+
+// CommentPolicy controls who may comment on a post
+type CommentPolicy string
+
+const (
+	CommentPolicyEveryone  CommentPolicy = "everyone"
+	CommentPolicyFollowers CommentPolicy = "followers"
+	CommentPolicyMentioned CommentPolicy = "mentioned"
+	CommentPolicyDisabled  CommentPolicy = "disabled"
+)
+
+// ShareType tells a plain repost apart from a quote that adds commentary
+type ShareType string
+
+const (
+	ShareTypeRepost ShareType = "repost"
+	ShareTypeQuote  ShareType = "quote"
+)
+
 // Post represents a post in the system
 type Post struct {
-	ID            uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	UserID        uuid.UUID      `json:"userId" gorm:"type:uuid;not null"`
-	Content       string         `json:"content" gorm:"type:text;not null"`
-	Image         *string        `json:"image,omitempty"`
-	LikesCount    int            `json:"likes" gorm:"default:0"`
-	CommentsCount int            `json:"comments" gorm:"default:0"`
-	SharesCount   int            `json:"shares" gorm:"default:0"`
-	SharedPostID  *uuid.UUID     `json:"sharedPostId,omitempty" gorm:"type:uuid"`
-	CreatedAt     time.Time      `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt     time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
-	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
-	IsLiked       *bool          `json:"isLiked,omitempty" gorm:"-"`
+	ID              uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID          uuid.UUID      `json:"userId" gorm:"type:uuid;not null;uniqueIndex:idx_posts_repost,where:share_type = 'repost' AND deleted_at IS NULL;index:idx_posts_author,priority:1"`
+	Content         string         `json:"content" gorm:"type:text;not null"`
+	Image           *string        `json:"image,omitempty"`
+	LikesCount      int            `json:"likes" gorm:"default:0"`
+	Reactions       map[string]int `json:"reactions,omitempty" gorm:"type:jsonb;serializer:json"`
+	CommentsCount   int            `json:"comments" gorm:"default:0"`
+	SharesCount     int            `json:"shares" gorm:"default:0"`
+	SharedPostID    *uuid.UUID     `json:"sharedPostId,omitempty" gorm:"type:uuid;uniqueIndex:idx_posts_repost;index"`
+	ShareType       ShareType      `json:"shareType,omitempty" gorm:"size:10"`
+	InReplyToID     *uuid.UUID     `json:"inReplyToId,omitempty" gorm:"type:uuid;index"`
+	RepliesCount    int            `json:"replies" gorm:"default:0"`
+	CommentPolicy   CommentPolicy  `json:"commentPolicy" gorm:"size:20;not null;default:'everyone'"`
+	PinnedCommentID *uuid.UUID     `json:"pinnedCommentId,omitempty" gorm:"type:uuid"`
+	Status          PostStatus     `json:"status" gorm:"size:20;not null;default:'published';index"`
+	PublishAt       *time.Time     `json:"publishAt,omitempty" gorm:"index"`
+	CreatedAt       time.Time      `json:"createdAt" gorm:"autoCreateTime;index:idx_posts_author,priority:2"`
+	UpdatedAt       time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	EditedAt        *time.Time     `json:"editedAt,omitempty"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+	IsLiked         *bool          `json:"isLiked,omitempty" gorm:"-"`
+	Reaction        *string        `json:"reaction,omitempty" gorm:"-"`
+	IsBookmarked    *bool          `json:"isBookmarked,omitempty" gorm:"-"`
+	BookmarkedAt    *time.Time     `json:"bookmarkedAt,omitempty" gorm:"->;-:migration"`
+	TrendingRank    *int           `json:"trendingRank,omitempty" gorm:"->;-:migration"`
+	IsReposted      *bool          `json:"isReposted,omitempty" gorm:"-"`
+	Processing      bool           `json:"processing,omitempty" gorm:"-"`
+	Deleted         bool           `json:"deleted,omitempty" gorm:"-"`
 
 	// Relations
-	Author       *User     `json:"author,omitempty" gorm:"foreignKey:UserID"`
-	LikesList    []Like    `json:"-" gorm:"foreignKey:PostID"`
-	CommentsList []Comment `json:"-" gorm:"foreignKey:PostID"`
-	SharedPost   *Post     `json:"sharedPost,omitempty" gorm:"foreignKey:SharedPostID"`
+	Author       *User       `json:"author,omitempty" gorm:"foreignKey:UserID"`
+	Media        []PostMedia `json:"media,omitempty" gorm:"foreignKey:PostID"`
+	Poll         *Poll       `json:"poll,omitempty" gorm:"foreignKey:PostID"`
+	LikesList    []Like      `json:"-" gorm:"foreignKey:PostID"`
+	CommentsList []Comment   `json:"-" gorm:"foreignKey:PostID"`
+	SharedPost   *Post       `json:"sharedPost,omitempty" gorm:"foreignKey:SharedPostID"`
 }
 
 // TableName specifies the table name for Post model
@@ -40,6 +85,19 @@ func (p *Post) BeforeCreate(tx *gorm.DB) error {
 		p.ID = uuid.New()
 	}
 
+	if p.Status == "" {
+		p.Status = PostStatusPublished
+	}
+
+	if p.CommentPolicy == "" {
+		p.CommentPolicy = CommentPolicyEveryone
+	}
+
+	// Drafts and scheduled posts are counted when they are published
+	if p.Status != PostStatusPublished {
+		return nil
+	}
+
 	// Increment user's post count on creation
 	err := tx.Model(&User{}).Where("id = ?", p.UserID).Update("posts_count", gorm.Expr("posts_count + 1")).Error
 	if err != nil {
@@ -49,32 +107,145 @@ func (p *Post) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// AfterFind flags posts whose attachments are still being processed
+func (p *Post) AfterFind(tx *gorm.DB) error {
+	p.Processing = false
+	for _, media := range p.Media {
+		if media.Status == MediaStatusProcessing {
+			p.Processing = true
+		}
+	}
+	return nil
+}
+
+// Tombstone strips a deleted post down to its place in a thread
+func (p *Post) Tombstone() {
+	p.Deleted = true
+	p.Content = ""
+	p.Image = nil
+	p.Reactions = nil
+	p.Author = nil
+	p.Media = nil
+	p.Poll = nil
+	p.SharedPost = nil
+	p.SharedPostID = nil
+}
+
+// MaxPostMedia is the maximum number of attachments a post can carry
+const MaxPostMedia = 4
+
+// MediaType represents the kind of a post attachment
+type MediaType string
+
+const (
+	MediaTypeImage MediaType = "image"
+	MediaTypeVideo MediaType = "video"
+)
+
+// MediaStatus represents whether an attachment can be displayed yet
+type MediaStatus string
+
+const (
+	MediaStatusReady      MediaStatus = "ready"
+	MediaStatusProcessing MediaStatus = "processing"
+	MediaStatusFailed     MediaStatus = "failed"
+)
+
+// PostMedia represents an ordered attachment of a post
+type PostMedia struct {
+	ID        uuid.UUID   `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PostID    uuid.UUID   `json:"-" gorm:"type:uuid;not null;index"`
+	Position  int         `json:"position" gorm:"not null;default:0"`
+	URL       string      `json:"url" gorm:"size:1000;not null;index"`
+	Type      MediaType   `json:"type" gorm:"size:20;not null;default:'image'"`
+	Status    MediaStatus `json:"status" gorm:"size:20;not null;default:'ready';index"`
+	Width     *int        `json:"width,omitempty"`
+	Height    *int        `json:"height,omitempty"`
+	AltText   *string     `json:"altText,omitempty" gorm:"size:1500"`
+	Blurhash  *string     `json:"blurhash,omitempty" gorm:"size:100"`
+	PosterURL *string     `json:"posterUrl,omitempty" gorm:"size:1000"`
+	Duration  *float64    `json:"duration,omitempty"`
+	CreatedAt time.Time   `json:"createdAt" gorm:"autoCreateTime"`
+
+	// UploadID is the upload the attachment was checked against, set when a post is
+	// saved so that the upload is referenced by it
+	UploadID uuid.UUID `json:"-" gorm:"-"`
+}
+
+// TableName specifies the table name for PostMedia model
+func (PostMedia) TableName() string {
+	return "post_media"
+}
+
+// BeforeCreate will set a UUID rather than numeric ID.
+func (m *PostMedia) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// PostMediaInput represents an attachment submitted with a post
+type PostMediaInput struct {
+	URL      string    `json:"url" binding:"required,url"`
+	Type     MediaType `json:"type,omitempty" binding:"omitempty,oneof=image video"`
+	Width    *int      `json:"width,omitempty" binding:"omitempty,min=1"`
+	Height   *int      `json:"height,omitempty" binding:"omitempty,min=1"`
+	AltText  *string   `json:"altText,omitempty" binding:"omitempty,max=1500"`
+	Blurhash *string   `json:"blurhash,omitempty" binding:"omitempty,max=100"`
+}
+
 // PostCreate represents data needed to create a new post
 type PostCreate struct {
-	Content string  `json:"content" binding:"required"`
-	Image   *string `json:"image,omitempty"`
+	Content string           `json:"content" binding:"required"`
+	Image   *string          `json:"image,omitempty"`
+	Media   []PostMediaInput `json:"media,omitempty" binding:"omitempty,max=4,dive"`
+	Poll    *PollCreate      `json:"poll,omitempty"`
+	// InReplyToID makes the post a reply in the thread of another post
+	InReplyToID   *uuid.UUID    `json:"inReplyToId,omitempty"`
+	CommentPolicy CommentPolicy `json:"commentPolicy,omitempty" binding:"omitempty,oneof=everyone followers mentioned disabled"`
 }
 
 // PostUpdate represents data that can be updated for a post
 type PostUpdate struct {
-	Content string  `json:"content" binding:"required"`
-	Image   *string `json:"image,omitempty"`
+	Content string           `json:"content" binding:"required"`
+	Image   *string          `json:"image,omitempty"`
+	Media   []PostMediaInput `json:"media,omitempty" binding:"omitempty,max=4,dive"`
+}
+
+// PostDraft represents data needed to save a draft. Setting PublishAt schedules the
+// draft to be published at that time.
+type PostDraft struct {
+	Content   string           `json:"content"`
+	Image     *string          `json:"image,omitempty"`
+	Media     []PostMediaInput `json:"media,omitempty" binding:"omitempty,max=4,dive"`
+	PublishAt *time.Time       `json:"publishAt,omitempty"`
 }
 
-// PostShare represents data needed to share a post
+// PostShare represents data needed to share a post. Shares without a type are quotes
+// when they carry content and reposts otherwise.
 type PostShare struct {
-	Content string `json:"content"`
+	Type    ShareType `json:"type,omitempty" binding:"omitempty,oneof=repost quote"`
+	Content string    `json:"content"`
 }
 
-// Comment represents a comment on a post
+// Comment represents a comment on a post. Replies to another comment point at it with
+// ParentID.
 type Comment struct {
-	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	UserID    uuid.UUID      `json:"userId" gorm:"type:uuid;not null"`
-	PostID    uuid.UUID      `json:"postId" gorm:"type:uuid;not null"`
-	Content   string         `json:"content" gorm:"type:text;not null"`
-	CreatedAt time.Time      `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID       uuid.UUID      `json:"userId" gorm:"type:uuid;not null"`
+	PostID       uuid.UUID      `json:"postId" gorm:"type:uuid;not null"`
+	ParentID     *uuid.UUID     `json:"parentId,omitempty" gorm:"type:uuid;index"`
+	Content      string         `json:"content" gorm:"type:text;not null"`
+	LikesCount   int            `json:"likes" gorm:"default:0"`
+	RepliesCount int            `json:"replies" gorm:"default:0"`
+	CreatedAt    time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	EditedAt     *time.Time     `json:"editedAt,omitempty"`
+	HiddenAt     *time.Time     `json:"hiddenAt,omitempty" gorm:"index"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	IsLiked      *bool          `json:"isLiked,omitempty" gorm:"-"`
+	Pinned       bool           `json:"pinned,omitempty" gorm:"-"`
 
 	// Relations
 	Author *User `json:"author,omitempty" gorm:"foreignKey:UserID"`
@@ -96,7 +267,18 @@ func (c *Comment) BeforeCreate(tx *gorm.DB) error {
 
 // CommentCreate represents data needed to create a new comment
 type CommentCreate struct {
-	Content string `json:"content" binding:"required"`
+	Content  string     `json:"content" binding:"required"`
+	ParentID *uuid.UUID `json:"parentId,omitempty"`
+}
+
+// CommentPolicyUpdate represents a change of who may comment on a post
+type CommentPolicyUpdate struct {
+	Policy CommentPolicy `json:"policy" binding:"required,oneof=everyone followers mentioned disabled"`
+}
+
+// PinnedCommentUpdate represents the comment a post owner pins to the top
+type PinnedCommentUpdate struct {
+	CommentID uuid.UUID `json:"commentId" binding:"required"`
 }
 
 // CommentUpdate represents data that can be updated for a comment
@@ -104,15 +286,32 @@ type CommentUpdate struct {
 	Content string `json:"content" binding:"required"`
 }
 
-// Like represents a like on a post
+// CommentLike represents a like on a comment
+type CommentLike struct {
+	UserID    uuid.UUID `json:"userId" gorm:"type:uuid;primaryKey"`
+	CommentID uuid.UUID `json:"commentId" gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	// Relations
+	User    *User    `json:"-" gorm:"foreignKey:UserID"`
+	Comment *Comment `json:"-" gorm:"foreignKey:CommentID"`
+}
+
+// TableName specifies the table name for CommentLike model
+func (CommentLike) TableName() string {
+	return "comment_likes"
+}
+
+// Like represents a reaction on a post. Plain likes are the default reaction.
 type Like struct {
 	UserID    uuid.UUID `json:"userId" gorm:"type:uuid;primaryKey"`
 	PostID    uuid.UUID `json:"postId" gorm:"type:uuid;primaryKey"`
+	Reaction  string    `json:"reaction" gorm:"size:32;not null;default:'like';index"`
 	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
 
 	// Relations
-	User User `json:"-" gorm:"foreignKey:UserID"`
-	Post Post `json:"-" gorm:"foreignKey:PostID"`
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Post *Post `json:"-" gorm:"foreignKey:PostID"`
 }
 
 // TableName specifies the table name for Like model
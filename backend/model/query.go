@@ -1,9 +1,22 @@
 package model
 
-// Pagination represents common pagination parameters
+import "time"
+
+// MaxPageSize is the largest page a list returns
+const MaxPageSize = 100
+
+// Pagination represents common pagination parameters; pages hold at most MaxPageSize
+// items. Lists ordered by time also take the opaque cursor returned with a page, which
+// takes precedence over Offset.
 type Pagination struct {
-	Limit  int `form:"limit,default=10"`
-	Offset int `form:"offset,default=0"`
+	Limit  int    `form:"limit,default=10"`
+	Offset int    `form:"offset,default=0" binding:"min=0"`
+	Cursor string `form:"cursor"`
+}
+
+// ClampLimit brings a requested page size into the range lists support
+func (p *Pagination) ClampLimit() {
+	p.Limit = min(max(p.Limit, 1), MaxPageSize)
 }
 
 // UserFilter represents user filtering parameters
@@ -25,6 +38,63 @@ type CommentFilter struct {
 	Pagination
 }
 
+// Home feed modes
+const (
+	FeedModeLatest = "latest"
+	FeedModeRanked = "ranked"
+)
+
+// FeedFilter represents home feed parameters. The ranked feed is a single page that is
+// re-ranked on every request, so it takes no cursor.
+type FeedFilter struct {
+	Mode string `form:"mode" binding:"omitempty,oneof=latest ranked"`
+	Pagination
+}
+
+// NewPostsFilter represents the parameters of a count of new home feed posts
+type NewPostsFilter struct {
+	Since time.Time `form:"since" binding:"required"`
+}
+
+// TrendingFilter represents trending post parameters
+type TrendingFilter struct {
+	Window string `form:"window" binding:"omitempty,oneof=1h 24h 7d"`
+	Pagination
+}
+
+// Comment sort orders
+const (
+	CommentSortNewest = "newest"
+	CommentSortOldest = "oldest"
+	CommentSortTop    = "top"
+)
+
+// CommentListFilter represents the sort order and page of a post's comments
+type CommentListFilter struct {
+	Sort string `form:"sort" binding:"omitempty,oneof=newest oldest top"`
+	Pagination
+}
+
+// ReactionFilter represents filtering parameters for the users who reacted to a post
+type ReactionFilter struct {
+	Reaction string `form:"reaction"`
+	Pagination
+}
+
+// BookmarkFilter represents bookmark filtering parameters
+type BookmarkFilter struct {
+	CollectionID string `form:"collectionId" binding:"omitempty,uuid"`
+	Pagination
+}
+
+// ThreadFilter represents thread filtering parameters. Pagination applies to the direct
+// replies of the requested post; Depth limits how deep their own replies are loaded.
+type ThreadFilter struct {
+	Mode  string `form:"mode" binding:"omitempty,oneof=all self"`
+	Depth int    `form:"depth,default=3" binding:"min=1,max=10"`
+	Pagination
+}
+
 // FollowFilter represents follow relationship filtering parameters
 type FollowFilter struct {
 	Pagination
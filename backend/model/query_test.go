@@ -0,0 +1,27 @@
+package model
+
+import "testing"
+
+func TestPaginationClampLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		want  int
+	}{
+		{"in range", 20, 20},
+		{"zero", 0, 1},
+		{"negative", -5, 1},
+		{"above maximum", 200, MaxPageSize},
+		{"maximum", MaxPageSize, MaxPageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := Pagination{Limit: tt.limit}
+			page.ClampLimit()
+			if page.Limit != tt.want {
+				t.Errorf("ClampLimit(%d) = %d, want %d", tt.limit, page.Limit, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package model
+
+import "socialnet/util"
+
+// ReactionLike is the default reaction; likes made before reactions existed use it
+const ReactionLike = "like"
+
+// StandardReactions is the fixed set of named reactions. Any single emoji may be used
+// as a custom reaction as well.
+var StandardReactions = []string{ReactionLike, "love", "haha", "wow", "sad", "angry"}
+
+// IsValidReaction reports whether r is a standard reaction or a single emoji
+func IsValidReaction(r string) bool {
+	for _, standard := range StandardReactions {
+		if r == standard {
+			return true
+		}
+	}
+	return util.IsEmoji(r)
+}
+
+// ReactionInput represents the reaction a user leaves on a post
+type ReactionInput struct {
+	Reaction string `json:"reaction" binding:"required,max=32"`
+}
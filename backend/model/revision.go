@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"socialnet/util"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PostRevision is a previous version of a post, recorded when the post is edited
+type PostRevision struct {
+	ID      uuid.UUID   `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PostID  uuid.UUID   `json:"postId" gorm:"type:uuid;not null;uniqueIndex:idx_post_revisions_version"`
+	Version int         `json:"version" gorm:"not null;uniqueIndex:idx_post_revisions_version"`
+	Content string      `json:"content" gorm:"type:text;not null"`
+	Image   *string     `json:"image,omitempty"`
+	Media   []PostMedia `json:"media,omitempty" gorm:"type:jsonb;serializer:json"`
+	// CreatedAt is the time this version was replaced by an edit
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	// Diff lists the changes the edit made to the content of this version
+	Diff []util.DiffOp `json:"diff,omitempty" gorm:"-"`
+}
+
+// TableName specifies the table name for PostRevision model
+func (PostRevision) TableName() string {
+	return "post_revisions"
+}
+
+// BeforeCreate will set a UUID rather than numeric ID.
+func (r *PostRevision) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// CommentRevision is a previous version of a comment, recorded when the comment is edited
+type CommentRevision struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	CommentID uuid.UUID `json:"commentId" gorm:"type:uuid;not null;uniqueIndex:idx_comment_revisions_version"`
+	Version   int       `json:"version" gorm:"not null;uniqueIndex:idx_comment_revisions_version"`
+	Content   string    `json:"content" gorm:"type:text;not null"`
+	// CreatedAt is the time this version was replaced by an edit
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	// Diff lists the changes the edit made to this version
+	Diff []util.DiffOp `json:"diff,omitempty" gorm:"-"`
+}
+
+// TableName specifies the table name for CommentRevision model
+func (CommentRevision) TableName() string {
+	return "comment_revisions"
+}
+
+// BeforeCreate will set a UUID rather than numeric ID.
+func (r *CommentRevision) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package model
+
+import "github.com/google/uuid"
+
+// ThreadModeSelf restricts a thread to the chain of posts its author wrote
+const ThreadModeSelf = "self"
+
+// Thread represents a post in its conversation: the posts it replies to, root first,
+// and a page of the replies below it
+type Thread struct {
+	Ancestors []Post        `json:"ancestors"`
+	Post      *Post         `json:"post"`
+	Replies   []*ThreadNode `json:"replies"`
+}
+
+// ThreadNode represents a reply and the replies below it
+type ThreadNode struct {
+	*Post
+	Replies []*ThreadNode `json:"children,omitempty"`
+	// MoreReplies counts the replies to this post that were not loaded
+	MoreReplies int `json:"moreReplies,omitempty"`
+}
+
+// BuildReplyTree nests replies under the posts they reply to. Replies are kept in the
+// order given; the returned nodes are the direct replies of parentID.
+func BuildReplyTree(parentID uuid.UUID, replies []Post) []*ThreadNode {
+	nodes := make(map[uuid.UUID]*ThreadNode, len(replies))
+	for i := range replies {
+		nodes[replies[i].ID] = &ThreadNode{Post: &replies[i]}
+	}
+
+	roots := make([]*ThreadNode, 0)
+	for i := range replies {
+		node := nodes[replies[i].ID]
+		if replies[i].InReplyToID == nil {
+			continue
+		}
+		if *replies[i].InReplyToID == parentID {
+			roots = append(roots, node)
+		} else if parent, ok := nodes[*replies[i].InReplyToID]; ok {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+
+	roots = pruneTombstones(roots)
+	countMoreReplies(roots)
+	return roots
+}
+
+// countMoreReplies sets how many replies of each node were left out of the tree
+func countMoreReplies(nodes []*ThreadNode) {
+	for _, node := range nodes {
+		countMoreReplies(node.Replies)
+		node.MoreReplies = max(node.RepliesCount-len(node.Replies), 0)
+	}
+}
+
+// pruneTombstones drops deleted replies that have nothing left below them
+func pruneTombstones(nodes []*ThreadNode) []*ThreadNode {
+	kept := nodes[:0]
+	for _, node := range nodes {
+		node.Replies = pruneTombstones(node.Replies)
+		if !node.Deleted || len(node.Replies) > 0 || node.RepliesCount > 0 {
+			kept = append(kept, node)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBuildReplyTreeCountsMoreReplies(t *testing.T) {
+	root := uuid.New()
+	reply := func(parent uuid.UUID, replies int) Post {
+		return Post{ID: uuid.New(), InReplyToID: &parent, RepliesCount: replies}
+	}
+
+	first := reply(root, 7)
+	second := reply(root, 0)
+	nested := reply(first.ID, 2)
+
+	tree := BuildReplyTree(root, []Post{first, second, nested})
+	if len(tree) != 2 {
+		t.Fatalf("got %d direct replies, want 2", len(tree))
+	}
+
+	tests := []struct {
+		name string
+		node *ThreadNode
+		want int
+	}{
+		{"partly loaded replies", tree[0], 6},
+		{"no replies", tree[1], 0},
+		{"replies below the loaded depth", tree[0].Replies[0], 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.node.MoreReplies != tt.want {
+				t.Errorf("MoreReplies = %d, want %d", tt.node.MoreReplies, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreadNodeJSONKeepsRepliesCount(t *testing.T) {
+	root := uuid.New()
+	parent := Post{ID: uuid.New(), InReplyToID: &root, RepliesCount: 3}
+	child := Post{ID: uuid.New(), InReplyToID: &parent.ID}
+
+	tree := BuildReplyTree(root, []Post{parent, child})
+	data, err := json.Marshal(tree[0])
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var got struct {
+		Replies     int               `json:"replies"`
+		Children    []json.RawMessage `json:"children"`
+		MoreReplies int               `json:"moreReplies"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got.Replies != 3 {
+		t.Errorf("replies = %d, want 3", got.Replies)
+	}
+	if len(got.Children) != 1 {
+		t.Errorf("got %d children, want 1", len(got.Children))
+	}
+	if got.MoreReplies != 2 {
+		t.Errorf("moreReplies = %d, want 2", got.MoreReplies)
+	}
+}
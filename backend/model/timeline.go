@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TimelineEntry puts a post on the home timeline of a follower of its author. Posts by
+// accounts with more followers than the pull threshold get no entries; they are read
+// straight from the posts table when a timeline is loaded. CreatedAt copies the post's
+// creation time so that timelines are paged on the entries alone.
+type TimelineEntry struct {
+	UserID    uuid.UUID `json:"userId" gorm:"type:uuid;primaryKey;index:idx_timeline_entries_author,priority:1;index:idx_timeline_entries_feed,priority:1"`
+	PostID    uuid.UUID `json:"postId" gorm:"type:uuid;primaryKey;index;index:idx_timeline_entries_feed,priority:3"`
+	AuthorID  uuid.UUID `json:"authorId" gorm:"type:uuid;not null;index:idx_timeline_entries_author,priority:2"`
+	CreatedAt time.Time `json:"createdAt" gorm:"not null;default:CURRENT_TIMESTAMP;index:idx_timeline_entries_feed,priority:2"`
+}
+
+// TableName specifies the table name for TimelineEntry model
+func (TimelineEntry) TableName() string {
+	return "timeline_entries"
+}
+
+// FanoutJob is a published post waiting to be copied to its author's followers'
+// timelines. Jobs are queued in the transaction that publishes the post.
+type FanoutJob struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PostID    uuid.UUID `json:"postId" gorm:"type:uuid;not null"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime;index"`
+}
+
+// TableName specifies the table name for FanoutJob model
+func (FanoutJob) TableName() string {
+	return "fanout_jobs"
+}
+
+// NewPostNotice is published to every server instance when a post has been fanned out,
+// so that each can tell its own connected followers of the author
+type NewPostNotice struct {
+	PostID   uuid.UUID `json:"p"`
+	AuthorID uuid.UUID `json:"a"`
+}
+
+// NewPostEvent is the payload of a new_post websocket event. It sums up the posts that
+// followed accounts published since the previous event, so clients know to offer a
+// refresh without loading anything.
+type NewPostEvent struct {
+	Count        int         `json:"count"`
+	AuthorIDs    []uuid.UUID `json:"authorIds"`
+	LatestPostID uuid.UUID   `json:"latestPostId"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (j *FanoutJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	return nil
+}
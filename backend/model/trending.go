@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EngagementKind is the kind of interaction an engagement event records
+type EngagementKind string
+
+const (
+	EngagementReaction EngagementKind = "reaction"
+	EngagementComment  EngagementKind = "comment"
+	EngagementShare    EngagementKind = "share"
+)
+
+// EngagementWeights rate how much each kind of engagement counts towards trending
+var EngagementWeights = map[EngagementKind]float64{
+	EngagementReaction: 1,
+	EngagementComment:  2,
+	EngagementShare:    3,
+}
+
+// DefaultTrendingWindow is the window trending posts are listed for when none is given
+const DefaultTrendingWindow = "24h"
+
+// TrendingWindows are the sliding windows trending posts are computed over
+var TrendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// EngagementEvent records one interaction with a post at the time it happened, so that
+// trending can be computed over recent activity rather than all-time counters
+type EngagementEvent struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PostID    uuid.UUID      `json:"postId" gorm:"type:uuid;not null;index"`
+	UserID    uuid.UUID      `json:"userId" gorm:"type:uuid;not null"`
+	Kind      EngagementKind `json:"kind" gorm:"size:20;not null"`
+	CreatedAt time.Time      `json:"createdAt" gorm:"autoCreateTime;index"`
+}
+
+// TableName specifies the table name for EngagementEvent model
+func (EngagementEvent) TableName() string {
+	return "engagement_events"
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (e *EngagementEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// TrendingPost is a post's place in the cached trending ranking of a window
+type TrendingPost struct {
+	Window string    `json:"window" gorm:"column:time_window;size:8;primaryKey"`
+	PostID uuid.UUID `json:"postId" gorm:"type:uuid;primaryKey"`
+	Rank   int       `json:"rank" gorm:"not null"`
+	Score  float64   `json:"score" gorm:"not null"`
+}
+
+// TableName specifies the table name for TrendingPost model
+func (TrendingPost) TableName() string {
+	return "trending_posts"
+}
@@ -0,0 +1,204 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UploadStatus represents the lifecycle state of an uploaded file
+type UploadStatus string
+
+const (
+	UploadStatusPending     UploadStatus = "pending"
+	UploadStatusUploading   UploadStatus = "uploading"
+	UploadStatusProcessing  UploadStatus = "processing"
+	UploadStatusTranscoding UploadStatus = "transcoding"
+	UploadStatusReady       UploadStatus = "ready"
+	UploadStatusFailed      UploadStatus = "failed"
+)
+
+// UploadKind represents the kind of media stored in an upload
+type UploadKind string
+
+const (
+	UploadKindImage UploadKind = "image"
+	UploadKindVideo UploadKind = "video"
+)
+
+const (
+	// uploadChunkPrefix is the private key prefix chunks of resumable uploads are stored under
+	uploadChunkPrefix = "private/chunks/"
+	// uploadQuarantinePrefix is the private key prefix presigned uploads are written to
+	// until they have been verified and scanned
+	uploadQuarantinePrefix = "private/quarantine/"
+)
+
+// UploadRefType represents the kind of entity that references an upload
+type UploadRefType string
+
+const (
+	UploadRefPost   UploadRefType = "post"
+	UploadRefAvatar UploadRefType = "avatar"
+	UploadRefCover  UploadRefType = "cover"
+)
+
+// Upload represents a file stored in the upload bucket. Key is the object served as
+// the file itself; processed images also list every size variant in Variants.
+//
+// Videos are uploaded in chunks that are stored privately until the transcoder turns
+// them into the web rendition stored under Key and a poster frame listed in Variants.
+type Upload struct {
+	ID          uuid.UUID         `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID      uuid.UUID         `json:"userId" gorm:"type:uuid;not null;index"`
+	Key         string            `json:"key" gorm:"size:500;not null;uniqueIndex"`
+	Kind        UploadKind        `json:"kind" gorm:"size:20;not null;default:'image'"`
+	ContentType string            `json:"contentType" gorm:"size:100;not null"`
+	Size        int64             `json:"size" gorm:"not null;default:0"`
+	Status      UploadStatus      `json:"status" gorm:"size:20;not null;default:'pending';index"`
+	Variants    map[string]string `json:"variants,omitempty" gorm:"type:jsonb;serializer:json"`
+	ChunkSize   int64             `json:"chunkSize,omitempty" gorm:"not null;default:0"`
+	Received    int64             `json:"received,omitempty" gorm:"not null;default:0"`
+	Width       *int              `json:"width,omitempty"`
+	Height      *int              `json:"height,omitempty"`
+	Duration    *float64          `json:"duration,omitempty"`
+	Error       *string           `json:"error,omitempty" gorm:"size:500"`
+	CreatedAt   time.Time         `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time         `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	// Relations
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// TableName specifies the table name for Upload model
+func (Upload) TableName() string {
+	return "uploads"
+}
+
+// BeforeCreate will set a UUID rather than numeric ID.
+func (u *Upload) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	return nil
+}
+
+// Keys returns the keys of every stored object that belongs to the upload
+func (u *Upload) Keys() []string {
+	keys := []string{u.Key}
+	for _, key := range u.Variants {
+		if key != u.Key {
+			keys = append(keys, key)
+		}
+	}
+	if u.Status == UploadStatusPending {
+		keys = append(keys, u.QuarantineKey())
+	}
+	return append(keys, u.ChunkKeys()...)
+}
+
+// QuarantineKey returns the private key a presigned upload is written to before it is
+// verified
+func (u *Upload) QuarantineKey() string {
+	return uploadQuarantinePrefix + u.ID.String() + path.Ext(u.Key)
+}
+
+// ChunkKey returns the key the chunk starting at offset is stored under
+func (u *Upload) ChunkKey(offset int64) string {
+	return fmt.Sprintf("%s%s/%016d", uploadChunkPrefix, u.ID, offset)
+}
+
+// ChunkKeys returns the keys of every chunk received for a resumable upload
+func (u *Upload) ChunkKeys() []string {
+	if u.ChunkSize <= 0 {
+		return nil
+	}
+
+	var keys []string
+	for offset := int64(0); offset < u.Received; offset += u.ChunkSize {
+		keys = append(keys, u.ChunkKey(offset))
+	}
+	return keys
+}
+
+// UploadReference records that an entity uses an upload. An upload may be referenced by
+// any number of posts and profiles and is only garbage collected once none are left.
+type UploadReference struct {
+	UploadID uuid.UUID     `json:"uploadId" gorm:"type:uuid;primaryKey"`
+	RefType  UploadRefType `json:"refType" gorm:"size:20;primaryKey;index:idx_upload_references_ref,priority:1"`
+	RefID    uuid.UUID     `json:"refId" gorm:"type:uuid;primaryKey;index:idx_upload_references_ref,priority:2"`
+}
+
+// TableName specifies the table name for UploadReference model
+func (UploadReference) TableName() string {
+	return "upload_references"
+}
+
+// UploadObject maps every stored object key of an upload, its main object and each of its
+// variants, back to the upload, so that an attached URL can be resolved with one index
+// lookup
+type UploadObject struct {
+	Key      string    `json:"key" gorm:"size:500;primaryKey"`
+	UploadID uuid.UUID `json:"uploadId" gorm:"type:uuid;not null;index"`
+}
+
+// TableName specifies the table name for UploadObject model
+func (UploadObject) TableName() string {
+	return "upload_objects"
+}
+
+// UploadPresign represents data needed to request a presigned upload URL
+type UploadPresign struct {
+	Filename    string `json:"filename" binding:"required,max=255"`
+	ContentType string `json:"contentType" binding:"required"`
+	Size        int64  `json:"size" binding:"required,min=1"`
+}
+
+// VideoUploadInit represents data needed to start a resumable video upload
+type VideoUploadInit struct {
+	Filename    string `json:"filename" binding:"required,max=255"`
+	ContentType string `json:"contentType" binding:"required"`
+	Size        int64  `json:"size" binding:"required,min=1"`
+}
+
+// UploadDailyUsage counts the uploads a user started on a day. Unlike storage usage it
+// is not reduced when uploads are deleted, so it acts as a rate limit.
+type UploadDailyUsage struct {
+	UserID uuid.UUID `json:"userId" gorm:"type:uuid;primaryKey"`
+	Day    time.Time `json:"day" gorm:"type:date;primaryKey"`
+	Count  int       `json:"count" gorm:"not null;default:0"`
+	Bytes  int64     `json:"bytes" gorm:"not null;default:0"`
+}
+
+// TableName specifies the table name for UploadDailyUsage model
+func (UploadDailyUsage) TableName() string {
+	return "upload_daily_usage"
+}
+
+// UploadStorageUsage counts the bytes a user's uploads take up, including uploads that
+// are still in progress. Uploads reserve their size here before anything is stored, so
+// that concurrent uploads cannot exceed the quota together.
+type UploadStorageUsage struct {
+	UserID uuid.UUID `json:"userId" gorm:"type:uuid;primaryKey"`
+	Bytes  int64     `json:"bytes" gorm:"not null;default:0"`
+}
+
+// TableName specifies the table name for UploadStorageUsage model
+func (UploadStorageUsage) TableName() string {
+	return "upload_storage_usage"
+}
+
+// StorageUsage summarises the storage a user consumes and their remaining quotas. Limits
+// of zero mean unlimited.
+type StorageUsage struct {
+	UsedBytes        int64 `json:"usedBytes"`
+	QuotaBytes       int64 `json:"quotaBytes"`
+	FileCount        int64 `json:"fileCount"`
+	UploadsToday     int   `json:"uploadsToday"`
+	DailyUploadLimit int   `json:"dailyUploadLimit"`
+	BytesToday       int64 `json:"bytesToday"`
+	DailyBytesLimit  int64 `json:"dailyBytesLimit"`
+}
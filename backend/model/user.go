@@ -27,6 +27,9 @@ type User struct {
 	UpdatedAt      time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
 	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 	IsFollowed     *bool          `json:"isFollowed,omitempty" gorm:"-"`
+	// ProfileUploads lists the uploads the avatar or cover use by reference type. It is
+	// only set when either changes, so that saving the user moves their references.
+	ProfileUploads map[UploadRefType][]uuid.UUID `json:"-" gorm:"-"`
 
 	// Relations
 	Posts    []Post    `json:"-" gorm:"foreignKey:UserID"`
@@ -76,6 +79,16 @@ type UserUpdate struct {
 	Website  *string `json:"website,omitempty"`
 }
 
+// ProfileImageUpdate represents an uploaded image and the rectangle of it, in pixels,
+// to use as avatar or cover
+type ProfileImageUpdate struct {
+	UploadID uuid.UUID `json:"uploadId" binding:"required"`
+	X        int       `json:"x" binding:"min=0"`
+	Y        int       `json:"y" binding:"min=0"`
+	Width    int       `json:"width" binding:"required,min=1"`
+	Height   int       `json:"height" binding:"required,min=1"`
+}
+
 // AuthResponse represents the response after successful authentication
 type AuthResponse struct {
 	Token string `json:"token"`
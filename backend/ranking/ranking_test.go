@@ -0,0 +1,153 @@
+package ranking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fixedScorer scores candidates from a table so that ordering can be tested apart from
+// the weighted signals
+type fixedScorer map[uuid.UUID]float64
+
+func (s fixedScorer) Score(candidate Candidate, now time.Time) float64 {
+	return s[candidate.PostID]
+}
+
+func TestRank(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	authorA, authorB, authorC := uuid.New(), uuid.New(), uuid.New()
+
+	// post builds a candidate whose ID is derived from its name, so failures read well
+	post := func(name string, author uuid.UUID, age time.Duration, engagement, affinity int) Candidate {
+		return Candidate{
+			PostID:     uuid.NewSHA1(uuid.NameSpaceOID, []byte(name)),
+			AuthorID:   author,
+			CreatedAt:  now.Add(-age),
+			Engagement: engagement,
+			Affinity:   affinity,
+		}
+	}
+
+	fresh := post("fresh", authorA, time.Hour, 0, 0)
+	older := post("older", authorB, 5*time.Hour, 0, 0)
+	stale := post("stale", authorC, 30*time.Hour, 0, 0)
+
+	rising := post("rising", authorA, time.Hour, 20, 0)
+	viral := post("viral", authorB, 48*time.Hour, 200, 0)
+
+	favourite := post("favourite", authorA, 2*time.Hour, 5, 4)
+	stranger := post("stranger", authorB, 2*time.Hour, 5, 0)
+	acquaintance := post("acquaintance", authorC, 2*time.Hour, 5, 1)
+
+	a1 := post("a1", authorA, time.Hour, 0, 0)
+	a2 := post("a2", authorA, 2*time.Hour, 0, 0)
+	a3 := post("a3", authorA, 3*time.Hour, 0, 0)
+	b1 := post("b1", authorB, 4*time.Hour, 0, 0)
+	c1 := post("c1", authorC, 5*time.Hour, 0, 0)
+	spaced := fixedScorer{a1.PostID: 5, a2.PostID: 4, a3.PostID: 3, b1.PostID: 2, c1.PostID: 1}
+
+	tests := []struct {
+		name       string
+		candidates []Candidate
+		scorer     Scorer
+		spacing    int
+		want       []Candidate
+	}{
+		{
+			name:       "recency decays with age",
+			candidates: []Candidate{stale, fresh, older},
+			scorer:     NewWeightedScorer(Weights{Recency: 1, HalfLife: 6 * time.Hour}),
+			want:       []Candidate{fresh, older, stale},
+		},
+		{
+			name:       "velocity beats a stale post with more engagement",
+			candidates: []Candidate{viral, rising},
+			scorer:     NewWeightedScorer(Weights{Velocity: 1}),
+			want:       []Candidate{rising, viral},
+		},
+		{
+			name:       "velocity outweighs recency for a much faster post",
+			candidates: []Candidate{fresh, post("hot", authorB, 3*time.Hour, 400, 0)},
+			scorer:     NewWeightedScorer(Weights{Recency: 1, Velocity: 1, HalfLife: 6 * time.Hour}),
+			want:       []Candidate{post("hot", authorB, 3*time.Hour, 400, 0), fresh},
+		},
+		{
+			name:       "affinity breaks ties between equal posts",
+			candidates: []Candidate{stranger, favourite, acquaintance},
+			scorer:     NewWeightedScorer(Weights{Recency: 1, Velocity: 1, Affinity: 1, HalfLife: 6 * time.Hour}),
+			want:       []Candidate{favourite, acquaintance, stranger},
+		},
+		{
+			name:       "equal scores rank newest first",
+			candidates: []Candidate{older, stale, fresh},
+			scorer:     NewWeightedScorer(Weights{}),
+			want:       []Candidate{fresh, older, stale},
+		},
+		{
+			name:       "spacing of one keeps the score order",
+			candidates: []Candidate{b1, a2, a1, c1, a3},
+			scorer:     spaced,
+			spacing:    1,
+			want:       []Candidate{a1, a2, a3, b1, c1},
+		},
+		{
+			name:       "authors are spread out",
+			candidates: []Candidate{a1, a2, a3, b1, c1},
+			scorer:     spaced,
+			spacing:    2,
+			want:       []Candidate{a1, b1, a2, c1, a3},
+		},
+		{
+			name:       "wider spacing pulls lower scored authors forward",
+			candidates: []Candidate{a1, a2, b1, c1},
+			scorer:     spaced,
+			spacing:    3,
+			want:       []Candidate{a1, b1, c1, a2},
+		},
+		{
+			name:       "only one author left falls back to score order",
+			candidates: []Candidate{a1, a2, a3, b1},
+			scorer:     spaced,
+			spacing:    3,
+			want:       []Candidate{a1, b1, a2, a3},
+		},
+		{
+			name:    "no candidates",
+			scorer:  spaced,
+			spacing: 2,
+			want:    []Candidate{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Rank(tt.candidates, tt.scorer, now, tt.spacing)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d candidates, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if got[i].PostID != tt.want[i].PostID {
+					t.Errorf("position %d: got post by %s created %s, want post by %s created %s", i,
+						got[i].AuthorID, got[i].CreatedAt.Format(time.RFC3339),
+						tt.want[i].AuthorID, tt.want[i].CreatedAt.Format(time.RFC3339))
+				}
+			}
+		})
+	}
+}
+
+func TestRankKeepsCandidates(t *testing.T) {
+	now := time.Now()
+	candidates := []Candidate{
+		{PostID: uuid.New(), AuthorID: uuid.New(), CreatedAt: now.Add(-2 * time.Hour)},
+		{PostID: uuid.New(), AuthorID: uuid.New(), CreatedAt: now.Add(-time.Hour)},
+	}
+	first := candidates[0].PostID
+
+	Rank(candidates, NewWeightedScorer(Weights{Recency: 1, HalfLife: time.Hour}), now, 2)
+	if candidates[0].PostID != first {
+		t.Error("Rank reordered the caller's candidates")
+	}
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"errors"
+	"socialnet/model"
+	"socialnet/util"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCollectionExists is returned when a user already has a collection with that name
+var ErrCollectionExists = errors.New("collection already exists")
+
+// BookmarkRepository handles database operations for bookmarks and their collections
+type BookmarkRepository struct {
+	db *gorm.DB
+}
+
+// NewBookmarkRepository creates a new BookmarkRepository
+func NewBookmarkRepository(db *gorm.DB) *BookmarkRepository {
+	return &BookmarkRepository{db}
+}
+
+// Save bookmarks a post, or moves an existing bookmark to another collection
+func (r *BookmarkRepository) Save(bookmark *model.Bookmark) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "post_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"collection_id"}),
+	}).Create(bookmark).Error
+}
+
+// Delete removes a bookmark and reports whether there was one
+func (r *BookmarkRepository) Delete(userID, postID uuid.UUID) (bool, error) {
+	result := r.db.Where("user_id = ? AND post_id = ?", userID, postID).Delete(&model.Bookmark{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindPosts returns the posts a user bookmarked, most recently saved first. Posts that
+// were deleted or are no longer published are left out.
+func (r *BookmarkRepository) FindPosts(userID uuid.UUID, filter model.BookmarkFilter) ([]model.Post, util.CursorMeta, error) {
+	query := r.db.Scopes(withPostRelations, publishedPosts).
+		Select("posts.*, bookmarks.created_at AS bookmarked_at").
+		Joins("JOIN bookmarks ON bookmarks.post_id = posts.id").
+		Where("bookmarks.user_id = ?", userID)
+	if filter.CollectionID != "" {
+		query = query.Where("bookmarks.collection_id = ?", filter.CollectionID)
+	}
+
+	return findPage(query, newestBookmarks, filter.Pagination, func(post *model.Post) util.Cursor {
+		return util.Cursor{CreatedAt: *post.BookmarkedAt, ID: post.ID}
+	})
+}
+
+// FillBookmarkInfo sets whether the user bookmarked each of the given posts
+func (r *BookmarkRepository) FillBookmarkInfo(userID *uuid.UUID, posts []model.Post) ([]model.Post, error) {
+	if userID == nil || len(posts) == 0 {
+		return posts, nil
+	}
+
+	postIDs := make([]uuid.UUID, len(posts))
+	for i := range posts {
+		postIDs[i] = posts[i].ID
+	}
+
+	var bookmarked []uuid.UUID
+	err := r.db.Model(&model.Bookmark{}).
+		Where("user_id = ? AND post_id IN ?", userID, postIDs).
+		Pluck("post_id", &bookmarked).Error
+	if err != nil {
+		return nil, err
+	}
+
+	bookmarkedMap := make(map[uuid.UUID]bool, len(bookmarked))
+	for _, id := range bookmarked {
+		bookmarkedMap[id] = true
+	}
+
+	for i := range posts {
+		isBookmarked := bookmarkedMap[posts[i].ID]
+		posts[i].IsBookmarked = &isBookmarked
+	}
+
+	return posts, nil
+}
+
+// CreateCollection adds a new bookmark collection
+func (r *BookmarkRepository) CreateCollection(collection *model.BookmarkCollection) error {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(collection)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCollectionExists
+	}
+	return nil
+}
+
+// FindCollection finds a bookmark collection by ID
+func (r *BookmarkRepository) FindCollection(id uuid.UUID) (*model.BookmarkCollection, error) {
+	var collection model.BookmarkCollection
+	if err := r.db.First(&collection, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// FindCollections returns a user's collections by name with the number of visible
+// bookmarks in each
+func (r *BookmarkRepository) FindCollections(userID uuid.UUID) ([]model.BookmarkCollection, error) {
+	var collections []model.BookmarkCollection
+	if err := r.db.Where("user_id = ?", userID).Order("name ASC").Find(&collections).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		CollectionID uuid.UUID
+		Count        int
+	}
+	err := r.db.Model(&model.Bookmark{}).
+		Select("bookmarks.collection_id, COUNT(*) AS count").
+		Joins("JOIN posts ON posts.id = bookmarks.post_id AND posts.deleted_at IS NULL").
+		Scopes(publishedPosts).
+		Where("bookmarks.user_id = ? AND bookmarks.collection_id IS NOT NULL", userID).
+		Group("bookmarks.collection_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	countMap := make(map[uuid.UUID]int, len(counts))
+	for _, count := range counts {
+		countMap[count.CollectionID] = count.Count
+	}
+	for i := range collections {
+		collections[i].BookmarksCount = countMap[collections[i].ID]
+	}
+
+	return collections, nil
+}
+
+// RenameCollection changes the name of a collection
+func (r *BookmarkRepository) RenameCollection(collection *model.BookmarkCollection, name string) error {
+	var count int64
+	if err := r.db.Model(&model.BookmarkCollection{}).
+		Where("user_id = ? AND name = ? AND id <> ?", collection.UserID, name, collection.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCollectionExists
+	}
+
+	collection.Name = name
+	return r.db.Model(collection).Update("name", name).Error
+}
+
+// DeleteCollection deletes a collection and keeps its bookmarks outside any collection
+func (r *BookmarkRepository) DeleteCollection(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Bookmark{}).Where("collection_id = ?", id).
+			Update("collection_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.BookmarkCollection{}, "id = ?", id).Error
+	})
+}
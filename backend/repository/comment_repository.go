@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"errors"
 	"socialnet/model"
+	"socialnet/util"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // CommentRepo implements CommentRepository
@@ -37,6 +41,19 @@ func (r *CommentRepo) Create(comment *model.Comment) error {
 		return err
 	}
 
+	if err := recordEngagement(tx, comment.PostID, comment.UserID, model.EngagementComment); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Increment parent comment's replies_count
+	if comment.ParentID != nil {
+		if err := tx.Model(&model.Comment{}).Where("id = ?", comment.ParentID).Update("replies_count", gorm.Expr("replies_count + 1")).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	return tx.Commit().Error
 }
 
@@ -50,12 +67,62 @@ func (r *CommentRepo) FindByID(id uuid.UUID) (*model.Comment, error) {
 	return &comment, nil
 }
 
-// Update updates a comment in the database
+// FindVisibleByID finds a comment by ID unless it is hidden from the viewer
+func (r *CommentRepo) FindVisibleByID(id uuid.UUID, viewerID *uuid.UUID) (*model.Comment, error) {
+	var comment model.Comment
+	err := r.db.Preload("Author").Scopes(visibleComments(viewerID)).First(&comment, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// Update updates a comment in the database, recording the replaced version when its
+// content changes
 func (r *CommentRepo) Update(comment *model.Comment) error {
-	return r.db.Save(comment).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var current model.Comment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", comment.ID).Error; err != nil {
+			return err
+		}
+
+		if current.Content != comment.Content {
+			var version int
+			if err := tx.Model(&model.CommentRevision{}).Where("comment_id = ?", comment.ID).
+				Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+				return err
+			}
+
+			revision := model.CommentRevision{
+				CommentID: comment.ID,
+				Version:   version + 1,
+				Content:   current.Content,
+			}
+			if err := tx.Create(&revision).Error; err != nil {
+				return err
+			}
+
+			editedAt := revision.CreatedAt
+			comment.EditedAt = &editedAt
+		}
+
+		// Only the edited columns are written; counters and moderation may have changed
+		// since the comment was loaded
+		return tx.Model(&current).Updates(map[string]any{
+			"content":   comment.Content,
+			"edited_at": comment.EditedAt,
+		}).Error
+	})
 }
 
-// Delete deletes a comment from the database
+// FindRevisions returns the previous versions of a comment, newest first
+func (r *CommentRepo) FindRevisions(commentID uuid.UUID) ([]model.CommentRevision, error) {
+	var revisions []model.CommentRevision
+	err := r.db.Where("comment_id = ?", commentID).Order("version DESC").Find(&revisions).Error
+	return revisions, err
+}
+
+// Delete deletes a comment and the replies below it from the database
 func (r *CommentRepo) Delete(id uuid.UUID) error {
 	// Use transaction to handle comment deletion and counter update
 	tx := r.db.Begin()
@@ -70,14 +137,54 @@ func (r *CommentRepo) Delete(id uuid.UUID) error {
 		return err
 	}
 
+	// Replies make no sense without what they reply to
+	var subtree []model.Comment
+	if err := tx.Raw(`WITH RECURSIVE subtree AS (
+			SELECT id, user_id FROM comments WHERE id = ?
+			UNION ALL
+			SELECT c.id, c.user_id FROM comments c JOIN subtree s ON c.parent_id = s.id WHERE c.deleted_at IS NULL
+		) SELECT id, user_id FROM subtree`, id).Scan(&subtree).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	ids := make([]uuid.UUID, len(subtree))
+	deletedBy := make(map[uuid.UUID]int)
+	for i, deleted := range subtree {
+		ids[i] = deleted.ID
+		deletedBy[deleted.UserID]++
+	}
+
+	// Deleted comments no longer count towards the post trending
+	for userID, count := range deletedBy {
+		if err := forgetEngagement(tx, comment.PostID, userID, model.EngagementComment, count); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	// Delete comment
-	if err := tx.Delete(&model.Comment{}, "id = ?", id).Error; err != nil {
+	if err := tx.Delete(&model.Comment{}, "id IN ?", ids).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
 	// Decrement post's comments_count
-	if err := tx.Model(&model.Post{}).Where("id = ?", comment.PostID).Update("comments_count", gorm.Expr("comments_count - 1")).Error; err != nil {
+	if err := tx.Model(&model.Post{}).Where("id = ?", comment.PostID).Update("comments_count", gorm.Expr("comments_count - ?", len(ids))).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Decrement parent comment's replies_count
+	if comment.ParentID != nil {
+		if err := tx.Model(&model.Comment{}).Where("id = ?", comment.ParentID).Update("replies_count", gorm.Expr("replies_count - 1")).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// Unpin the comment if it was pinned
+	if err := tx.Model(&model.Post{}).Where("id = ? AND pinned_comment_id IN ?", comment.PostID, ids).Update("pinned_comment_id", nil).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -85,14 +192,166 @@ func (r *CommentRepo) Delete(id uuid.UUID) error {
 	return tx.Commit().Error
 }
 
-// FindByPostID finds all comments for a post with pagination
-func (r *CommentRepo) FindByPostID(postID uuid.UUID, filter model.Pagination) ([]model.Comment, error) {
-	var comments []model.Comment
-	err := r.db.Preload("Author").
-		Where("post_id = ?", postID).
-		Order("created_at DESC").
-		Limit(filter.Limit).Offset(filter.Offset).
-		Find(&comments).Error
+// visibleComments leaves out hidden comments unless the viewer wrote them
+func visibleComments(viewerID *uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if viewerID == nil {
+			return db.Where("comments.hidden_at IS NULL")
+		}
+		return db.Where("comments.hidden_at IS NULL OR comments.user_id = ?", viewerID)
+	}
+}
+
+// FindByPostID finds a page of the top-level comments for a post in the requested
+// order. The pinned comment is left out; it is fetched on its own to be shown first.
+func (r *CommentRepo) FindByPostID(postID uuid.UUID, viewerID *uuid.UUID, filter model.CommentListFilter) ([]model.Comment, util.CursorMeta, error) {
+	query := r.db.Preload("Author").
+		Scopes(visibleComments(viewerID)).
+		Where("post_id = ? AND parent_id IS NULL", postID).
+		Where("id IS DISTINCT FROM (SELECT pinned_comment_id FROM posts WHERE posts.id = ?)", postID)
+
+	switch filter.Sort {
+	case model.CommentSortOldest:
+		return findPage(query, oldestComments, filter.Pagination, commentPosition)
+	case model.CommentSortTop:
+		return findPage(query, topComments, filter.Pagination, func(comment *model.Comment) util.Cursor {
+			return util.Cursor{Score: comment.LikesCount + comment.RepliesCount, CreatedAt: comment.CreatedAt, ID: comment.ID}
+		})
+	default:
+		return findPage(query, newestComments, filter.Pagination, commentPosition)
+	}
+}
+
+// FindReplies finds a page of the direct replies to a comment, oldest first
+func (r *CommentRepo) FindReplies(commentID uuid.UUID, viewerID *uuid.UUID, filter model.Pagination) ([]model.Comment, util.CursorMeta, error) {
+	query := r.db.Preload("Author").
+		Scopes(visibleComments(viewerID)).
+		Where("parent_id = ?", commentID)
+
+	return findPage(query, oldestComments, filter, commentPosition)
+}
+
+// commentPosition returns the cursor of a comment in a list ordered by creation
+func commentPosition(comment *model.Comment) util.Cursor {
+	return util.Cursor{CreatedAt: comment.CreatedAt, ID: comment.ID}
+}
+
+// SetHidden hides a comment from everyone but its author, or shows it again. A hidden
+// comment can't stay pinned.
+func (r *CommentRepo) SetHidden(comment *model.Comment, hidden bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var hiddenAt *time.Time
+		if hidden {
+			now := time.Now()
+			hiddenAt = &now
+		}
+
+		if err := tx.Model(comment).Update("hidden_at", hiddenAt).Error; err != nil {
+			return err
+		}
+		comment.HiddenAt = hiddenAt
+
+		if !hidden {
+			return nil
+		}
+		return tx.Model(&model.Post{}).Where("id = ? AND pinned_comment_id = ?", comment.PostID, comment.ID).
+			Update("pinned_comment_id", nil).Error
+	})
+}
+
+// Like adds a like to a comment
+func (r *CommentRepo) Like(userID, commentID uuid.UUID) (int, error) {
+	// Use transaction to handle like creation and counter update
+	tx := r.db.Begin()
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	like := model.CommentLike{
+		UserID:    userID,
+		CommentID: commentID,
+	}
+
+	// Create like
+	if err := tx.Create(&like).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	var updatedComment model.Comment
+	// Increment comment's likes_count
+	if err := tx.Model(&updatedComment).Clauses(clause.Returning{}).Where("id = ?", commentID).Update("likes_count", gorm.Expr("likes_count + 1")).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return updatedComment.LikesCount, tx.Commit().Error
+}
+
+// Unlike removes a like from a comment
+func (r *CommentRepo) Unlike(userID, commentID uuid.UUID) (int, error) {
+	// Use transaction to handle like removal and counter update
+	tx := r.db.Begin()
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	// Delete like
+	result := tx.Where("user_id = ? AND comment_id = ?", userID, commentID).Delete(&model.CommentLike{})
+	if result.Error != nil {
+		tx.Rollback()
+		return 0, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return 0, errors.New("like not found")
+	}
+
+	var updatedComment model.Comment
+	if err := tx.Model(&updatedComment).Clauses(clause.Returning{}).Where("id = ?", commentID).Update("likes_count", gorm.Expr("likes_count - 1")).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return updatedComment.LikesCount, tx.Commit().Error
+}
+
+// IsLiked checks if a comment is liked by a user
+func (r *CommentRepo) IsLiked(userID, commentID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.CommentLike{}).Where("user_id = ? AND comment_id = ?", userID, commentID).Count(&count).Error
+	return count > 0, err
+}
+
+// FillLikeInfo sets whether the user liked each of the given comments
+func (r *CommentRepo) FillLikeInfo(userID *uuid.UUID, comments []model.Comment) ([]model.Comment, error) {
+	if userID == nil || len(comments) == 0 {
+		return comments, nil
+	}
+
+	commentIDs := make([]uuid.UUID, len(comments))
+	for i := range comments {
+		commentIDs[i] = comments[i].ID
+	}
+
+	var liked []uuid.UUID
+	err := r.db.Model(&model.CommentLike{}).
+		Where("user_id = ? AND comment_id IN ?", userID, commentIDs).
+		Pluck("comment_id", &liked).Error
+	if err != nil {
+		return nil, err
+	}
+
+	likedMap := make(map[uuid.UUID]bool, len(liked))
+	for _, id := range liked {
+		likedMap[id] = true
+	}
+
+	for i := range comments {
+		isLiked := likedMap[comments[i].ID]
+		comments[i].IsLiked = &isLiked
+	}
 
-	return comments, err
+	return comments, nil
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"slices"
 	"socialnet/model"
+	"socialnet/util"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -188,27 +189,17 @@ func (r *MessageRepository) CreateMessage(message *model.Message) error {
 	})
 }
 
-// FindMessages gets all messages in a conversation
-func (r *MessageRepository) FindMessages(conversationID uuid.UUID, filter model.MessageFilter) ([]model.Message, error) {
-	var messages []model.Message
+// FindMessages gets a page of the messages in a conversation. Pages run from the latest
+// messages back; the next cursor leads to older messages.
+func (r *MessageRepository) FindMessages(conversationID uuid.UUID, filter model.MessageFilter) ([]model.Message, util.CursorMeta, error) {
 	// Get messages between these users
-	query := r.db.Where(
-		"conversation_id = ?", conversationID,
-	).Order("created_at DESC")
+	query := r.db.Where("conversation_id = ?", conversationID)
 
-	// Apply pagination if specified
-	if filter.Limit > 0 {
-		query = query.Limit(filter.Limit)
-	} else {
-		query = query.Limit(50) // Default limit
-	}
-
-	if filter.Offset > 0 {
-		query = query.Offset(filter.Offset)
-	}
-
-	if err := query.Find(&messages).Error; err != nil {
-		return nil, err
+	messages, meta, err := findPage(query, newestMessages, filter.Pagination, func(message *model.Message) util.Cursor {
+		return util.Cursor{CreatedAt: message.CreatedAt, ID: message.ID}
+	})
+	if err != nil {
+		return nil, meta, err
 	}
 
 	// Reverse the messages order to be chronological
@@ -216,7 +207,7 @@ func (r *MessageRepository) FindMessages(conversationID uuid.UUID, filter model.
 		messages[i], messages[j] = messages[j], messages[i]
 	}
 
-	return messages, nil
+	return messages, meta, nil
 }
 
 // MarkMessagesAsRead marks all messages in a conversation as read for a user
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"socialnet/model"
+	"socialnet/util"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -32,24 +33,21 @@ func (r *NotificationRepository) FindByID(id uuid.UUID) (*model.Notification, er
 	return &notification, nil
 }
 
-// FindByUserID finds notifications for a user with filters
-func (r *NotificationRepository) FindByUserID(userID uuid.UUID, filter model.NotificationFilter) ([]model.Notification, error) {
-	var notifications []model.Notification
-	query := r.db.Where("user_id = ?", userID).Order("created_at DESC")
+// FindByUserID finds a page of a user's notifications with filters, newest first
+func (r *NotificationRepository) FindByUserID(userID uuid.UUID, filter model.NotificationFilter) ([]model.Notification, util.CursorMeta, error) {
+	query := r.db.Where("user_id = ?", userID)
 
 	// Apply read filter if provided
 	if filter.IsRead != nil {
 		query = query.Where("is_read = ?", *filter.IsRead)
 	}
 
-	// Apply pagination
-	query = query.Limit(filter.Limit).Offset(filter.Offset)
-
 	// Include sender information
 	query = query.Preload("Sender")
 
-	err := query.Find(&notifications).Error
-	return notifications, err
+	return findPage(query, newestNotifications, filter.Pagination, func(notification *model.Notification) util.Cursor {
+		return util.Cursor{CreatedAt: notification.CreatedAt, ID: notification.ID}
+	})
 }
 
 // MarkAsRead marks a notification as read
@@ -147,6 +145,74 @@ func (r *NotificationRepository) CreateCommentNotification(commenterID, postOwne
 	return r.Create(&notification)
 }
 
+// CreatePollClosedNotification tells a post owner that the poll of their post has closed
+func (r *NotificationRepository) CreatePollClosedNotification(postOwnerID, postID uuid.UUID) error {
+	entityType := "post"
+	notification := model.Notification{
+		UserID:          postOwnerID,
+		Type:            model.NotificationTypePollClosed,
+		Message:         "Your poll has ended, see the results",
+		RelatedEntityID: &postID,
+		EntityType:      &entityType,
+	}
+
+	return r.Create(&notification)
+}
+
+// CreateCommentLikeNotification creates a notification for a liked comment
+func (r *NotificationRepository) CreateCommentLikeNotification(userID, commentOwnerID uuid.UUID, commentID uuid.UUID) error {
+	// Don't notify yourself
+	if userID == commentOwnerID {
+		return nil
+	}
+
+	// Get user details
+	var user model.User
+	if err := r.db.First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+
+	// Create notification
+	entityType := "comment"
+	notification := model.Notification{
+		UserID:          commentOwnerID,
+		SenderID:        &userID,
+		Type:            model.NotificationTypeCommentLike,
+		Message:         user.Name + " liked your comment",
+		RelatedEntityID: &commentID,
+		EntityType:      &entityType,
+	}
+
+	return r.Create(&notification)
+}
+
+// CreateReplyNotification creates a notification for a reply to a comment
+func (r *NotificationRepository) CreateReplyNotification(replierID, commentOwnerID uuid.UUID, replyID uuid.UUID) error {
+	// Don't notify yourself
+	if replierID == commentOwnerID {
+		return nil
+	}
+
+	// Get replier details
+	var replier model.User
+	if err := r.db.First(&replier, "id = ?", replierID).Error; err != nil {
+		return err
+	}
+
+	// Create notification
+	entityType := "comment"
+	notification := model.Notification{
+		UserID:          commentOwnerID,
+		SenderID:        &replierID,
+		Type:            model.NotificationTypeReply,
+		Message:         replier.Name + " replied to your comment",
+		RelatedEntityID: &replyID,
+		EntityType:      &entityType,
+	}
+
+	return r.Create(&notification)
+}
+
 // CreateMessageNotification creates a message notification
 func (r *NotificationRepository) CreateMessageNotification(senderID, recipientID uuid.UUID, conversationID uuid.UUID) error {
 	// Get sender details
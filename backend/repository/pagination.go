@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"socialnet/model"
+	"socialnet/util"
+
+	"gorm.io/gorm"
+)
+
+// keyset describes the order of a cursor-paginated list: an optional score, then a
+// timestamp with a unique ID breaking ties
+type keyset struct {
+	sort      string // recorded in cursors so that they can't be used with another order
+	score     string
+	createdAt string
+	id        string
+	ascending bool
+}
+
+// Orders of the cursor-paginated lists
+var (
+	newestPosts         = keyset{sort: "newest", createdAt: "posts.created_at", id: "posts.id"}
+	newestComments      = keyset{sort: model.CommentSortNewest, createdAt: "comments.created_at", id: "comments.id"}
+	oldestComments      = keyset{sort: model.CommentSortOldest, createdAt: "comments.created_at", id: "comments.id", ascending: true}
+	topComments         = keyset{sort: model.CommentSortTop, score: "(comments.likes_count + comments.replies_count)", createdAt: "comments.created_at", id: "comments.id"}
+	newestLikes         = keyset{sort: "reactions", createdAt: "likes.created_at", id: "likes.user_id"}
+	newestFollowers     = keyset{sort: "followers", createdAt: "follows.created_at", id: "follows.follower_id"}
+	newestFollowing     = keyset{sort: "following", createdAt: "follows.created_at", id: "follows.following_id"}
+	newestBookmarks     = keyset{sort: "bookmarks", createdAt: "bookmarks.created_at", id: "posts.id"}
+	newestMessages      = keyset{sort: "messages", createdAt: "messages.created_at", id: "messages.id"}
+	newestNotifications = keyset{sort: "notifications", createdAt: "notifications.created_at", id: "notifications.id"}
+)
+
+// columns returns the columns the list is ordered by
+func (k keyset) columns() []string {
+	if k.score != "" {
+		return []string{k.score, k.createdAt, k.id}
+	}
+	return []string{k.createdAt, k.id}
+}
+
+// values returns the position of a cursor in the list's columns
+func (k keyset) values(cursor *util.Cursor) []interface{} {
+	if k.score != "" {
+		return []interface{}{cursor.Score, cursor.CreatedAt, cursor.ID}
+	}
+	return []interface{}{cursor.CreatedAt, cursor.ID}
+}
+
+// findPage loads one page of a list in keyset order. Without a cursor it falls back to
+// the filter's offset. Besides the rows it returns the cursors of the pages after and
+// before them; a page after a cursor isn't shifted by rows added in the meantime.
+// position returns the cursor of a row, without its sort and direction.
+func findPage[T any](query *gorm.DB, key keyset, filter model.Pagination, position func(*T) util.Cursor) ([]T, util.CursorMeta, error) {
+	var meta util.CursorMeta
+
+	var cursor *util.Cursor
+	if filter.Cursor != "" {
+		var err error
+		if cursor, err = util.DecodeCursor(filter.Cursor, key.sort); err != nil {
+			return nil, meta, err
+		}
+	}
+
+	// Pages before a cursor are read in reverse order and flipped back
+	backward := cursor != nil && cursor.Before
+	ascending := key.ascending != backward
+
+	columns := key.columns()
+	if cursor != nil {
+		op := "<"
+		if ascending {
+			op = ">"
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+		query = query.Where(fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), op, placeholders), key.values(cursor)...)
+	} else {
+		query = query.Offset(filter.Offset)
+	}
+
+	direction := " DESC"
+	if ascending {
+		direction = " ASC"
+	}
+	for _, column := range columns {
+		query = query.Order(column + direction)
+	}
+
+	// Fetch one extra row to learn whether the list goes on
+	var rows []T
+	if err := query.Limit(filter.Limit + 1).Find(&rows).Error; err != nil {
+		return nil, meta, err
+	}
+	more := len(rows) > filter.Limit
+	if more {
+		rows = rows[:filter.Limit]
+	}
+	if backward {
+		slices.Reverse(rows)
+	}
+	if len(rows) == 0 {
+		return rows, meta, nil
+	}
+
+	hasNext, hasPrev := more, cursor != nil || filter.Offset > 0
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		next := position(&rows[len(rows)-1])
+		next.Sort = key.sort
+		meta.NextCursor = next.Encode()
+	}
+	if hasPrev {
+		prev := position(&rows[0])
+		prev.Sort, prev.Before = key.sort, true
+		meta.PrevCursor = prev.Encode()
+	}
+	return rows, meta, nil
+}
+
+// postPosition returns the cursor of a post in a list ordered by creation
+func postPosition(post *model.Post) util.Cursor {
+	return util.Cursor{CreatedAt: post.CreatedAt, ID: post.ID}
+}
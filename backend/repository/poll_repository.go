@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"errors"
+	"socialnet/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPollClosed is returned when voting in a poll that has closed
+	ErrPollClosed = errors.New("poll is closed")
+	// ErrAlreadyVoted is returned when a user votes twice in the same poll
+	ErrAlreadyVoted = errors.New("already voted in this poll")
+	// ErrInvalidPollChoice is returned when the chosen options don't fit the poll
+	ErrInvalidPollChoice = errors.New("invalid poll choice")
+)
+
+// PollRepository handles database operations for post polls
+type PollRepository struct {
+	db *gorm.DB
+}
+
+// NewPollRepository creates a new PollRepository
+func NewPollRepository(db *gorm.DB) *PollRepository {
+	return &PollRepository{db}
+}
+
+// orderPollOptions keeps poll options in the order they were submitted
+func orderPollOptions(db *gorm.DB) *gorm.DB {
+	return db.Order("poll_options.position ASC")
+}
+
+// FindByPostID finds the poll of a post with its options
+func (r *PollRepository) FindByPostID(postID uuid.UUID) (*model.Poll, error) {
+	var poll model.Poll
+	if err := r.db.Preload("Options", orderPollOptions).First(&poll, "post_id = ?", postID).Error; err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+// Vote records a user's ballot and counts it towards the chosen options
+func (r *PollRepository) Vote(pollID, userID uuid.UUID, optionIDs []uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var poll model.Poll
+		if err := tx.Preload("Options").First(&poll, "id = ?", pollID).Error; err != nil {
+			return err
+		}
+
+		if poll.IsClosed(time.Now()) {
+			return ErrPollClosed
+		}
+
+		choices, err := pollChoices(&poll, optionIDs)
+		if err != nil {
+			return err
+		}
+
+		// The primary key allows a single ballot per user and poll
+		vote := model.PollVote{
+			PollID:    pollID,
+			UserID:    userID,
+			OptionIDs: choices,
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&vote)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyVoted
+		}
+
+		if err := tx.Model(&model.PollOption{}).Where("id IN ?", choices).
+			Update("votes_count", gorm.Expr("votes_count + 1")).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&model.Poll{}).Where("id = ?", pollID).
+			Update("voters_count", gorm.Expr("voters_count + 1")).Error
+	})
+}
+
+// pollChoices checks that the chosen options belong to the poll and that a single
+// choice poll gets exactly one of them
+func pollChoices(poll *model.Poll, optionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	valid := make(map[uuid.UUID]bool, len(poll.Options))
+	for _, option := range poll.Options {
+		valid[option.ID] = true
+	}
+
+	seen := make(map[uuid.UUID]bool, len(optionIDs))
+	choices := make([]uuid.UUID, 0, len(optionIDs))
+	for _, id := range optionIDs {
+		if !valid[id] {
+			return nil, ErrInvalidPollChoice
+		}
+		if !seen[id] {
+			seen[id] = true
+			choices = append(choices, id)
+		}
+	}
+
+	if len(choices) == 0 || (!poll.MultipleChoice && len(choices) > 1) {
+		return nil, ErrInvalidPollChoice
+	}
+	return choices, nil
+}
+
+// FillPollInfo marks the options the user voted for in the polls of the given posts
+// and reveals results of polls the user voted in or that have closed
+func (r *PollRepository) FillPollInfo(userID *uuid.UUID, posts []model.Post) ([]model.Post, error) {
+	polls := make(map[uuid.UUID]*model.Poll)
+	for i := range posts {
+		if posts[i].Poll != nil {
+			polls[posts[i].Poll.ID] = posts[i].Poll
+		}
+		if posts[i].SharedPost != nil && posts[i].SharedPost.Poll != nil {
+			polls[posts[i].SharedPost.Poll.ID] = posts[i].SharedPost.Poll
+		}
+	}
+	if len(polls) == 0 {
+		return posts, nil
+	}
+
+	voted := make(map[uuid.UUID][]uuid.UUID)
+	if userID != nil {
+		pollIDs := make([]uuid.UUID, 0, len(polls))
+		for id := range polls {
+			pollIDs = append(pollIDs, id)
+		}
+
+		var votes []model.PollVote
+		if err := r.db.Where("user_id = ? AND poll_id IN ?", userID, pollIDs).Find(&votes).Error; err != nil {
+			return nil, err
+		}
+		for _, vote := range votes {
+			voted[vote.PollID] = vote.OptionIDs
+		}
+	}
+
+	for id, poll := range polls {
+		choices, hasVoted := voted[id]
+		if userID != nil {
+			poll.HasVoted = &hasVoted
+		}
+
+		for i := range poll.Options {
+			for _, choice := range choices {
+				if poll.Options[i].ID == choice {
+					poll.Options[i].Voted = true
+				}
+			}
+		}
+
+		if hasVoted || poll.Closed {
+			poll.RevealResults()
+		}
+	}
+
+	return posts, nil
+}
+
+// CloseExpired closes up to limit expired polls and returns them. Expired polls are
+// locked with SKIP LOCKED and closed with a conditional update, so each poll is closed
+// exactly once even with several workers running.
+func (r *PollRepository) CloseExpired(now time.Time, limit int) ([]model.Poll, error) {
+	var closed []model.Poll
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var expired []model.Poll
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("closed_at IS NULL AND expires_at <= ?", now).
+			Order("expires_at ASC").
+			Limit(limit).
+			Find(&expired).Error; err != nil {
+			return err
+		}
+
+		for i := range expired {
+			result := tx.Model(&expired[i]).Where("closed_at IS NULL").Update("closed_at", now)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				closed = append(closed, expired[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return closed, nil
+}
@@ -1,14 +1,26 @@
 package repository
 
 import (
+	"database/sql"
 	"errors"
 	"gorm.io/gorm/clause"
 	"socialnet/model"
+	"socialnet/util"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrPostAlreadyPublished is returned when a draft is changed after it was published
+	ErrPostAlreadyPublished = errors.New("post is already published")
+	// ErrAlreadyReposted is returned when a user reposts a post twice
+	ErrAlreadyReposted = errors.New("post already reposted")
+	// ErrNotReposted is returned when undoing a repost that doesn't exist
+	ErrNotReposted = errors.New("post not reposted")
+)
+
 // PostRepo implements PostRepository
 type PostRepo struct {
 	db *gorm.DB
@@ -19,15 +31,69 @@ func NewPostRepository(db *gorm.DB) *PostRepo {
 	return &PostRepo{db}
 }
 
-// Create adds a new post to the database
+// orderMedia keeps post attachments in the order they were submitted
+func orderMedia(db *gorm.DB) *gorm.DB {
+	return db.Order("post_media.position ASC")
+}
+
+// withPostRelations preloads the relations returned alongside every post
+func withPostRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Author").
+		Preload("Media", orderMedia).
+		Preload("Poll").
+		Preload("Poll.Options", orderPollOptions).
+		Preload("SharedPost").
+		Preload("SharedPost.Author").
+		Preload("SharedPost.Media", orderMedia).
+		Preload("SharedPost.Poll").
+		Preload("SharedPost.Poll.Options", orderPollOptions)
+}
+
+// publishedPosts excludes drafts and scheduled posts
+func publishedPosts(db *gorm.DB) *gorm.DB {
+	return db.Where("posts.status = ?", model.PostStatusPublished)
+}
+
+// mediaUploads returns the uploads a post's attachments were checked against
+func mediaUploads(post *model.Post) []uuid.UUID {
+	var uploadIDs []uuid.UUID
+	for _, media := range post.Media {
+		if media.UploadID != uuid.Nil {
+			uploadIDs = append(uploadIDs, media.UploadID)
+		}
+	}
+	return uploadIDs
+}
+
+// Create adds a new post to the database, references the uploads of its attachments and
+// counts it as a reply to the post it replies to
 func (r *PostRepo) Create(post *model.Post) error {
-	return r.db.Create(post).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(post).Error; err != nil {
+			return err
+		}
+		if err := referenceUploads(tx, model.UploadRefPost, post.ID, mediaUploads(post), false); err != nil {
+			return err
+		}
+
+		if post.Status != model.PostStatusPublished {
+			return nil
+		}
+		if err := enqueueFanout(tx, post.ID); err != nil {
+			return err
+		}
+
+		if post.InReplyToID == nil {
+			return nil
+		}
+		return tx.Model(&model.Post{}).Where("id = ?", post.InReplyToID).Update("replies_count", gorm.Expr("replies_count + 1")).Error
+	})
 }
 
-// FindByID finds a post by ID with author preloaded
+// FindByID finds a published post by ID with author and media preloaded
 func (r *PostRepo) FindByID(id uuid.UUID) (*model.Post, error) {
 	var post model.Post
-	err := r.db.Preload("Author").First(&post, "id = ?", id).Error
+	err := r.db.Scopes(withPostRelations, publishedPosts).First(&post, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -35,9 +101,107 @@ func (r *PostRepo) FindByID(id uuid.UUID) (*model.Post, error) {
 	return &post, nil
 }
 
-// Update updates a post in the database
+// Update updates a post in the database and replaces its media attachments. The uploads
+// of replaced attachments stay referenced by published posts, whose revisions still
+// show them; drafts release them.
 func (r *PostRepo) Update(post *model.Post) error {
-	return r.db.Save(post).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Keep the version being replaced so readers can see what was edited
+		var current model.Post
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", post.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Scopes(orderMedia).Where("post_id = ?", post.ID).Find(&current.Media).Error; err != nil {
+			return err
+		}
+
+		// A draft may have been published by the scheduler since it was loaded
+		if current.Status == model.PostStatusPublished && post.Status != model.PostStatusPublished {
+			return ErrPostAlreadyPublished
+		}
+
+		// Drafts are edited freely; only edits of published posts are recorded
+		if current.Status == model.PostStatusPublished && !samePostContent(&current, post) {
+			var version int
+			if err := tx.Model(&model.PostRevision{}).Where("post_id = ?", post.ID).
+				Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+				return err
+			}
+
+			revision := model.PostRevision{
+				PostID:  post.ID,
+				Version: version + 1,
+				Content: current.Content,
+				Image:   current.Image,
+				Media:   current.Media,
+			}
+			if err := tx.Create(&revision).Error; err != nil {
+				return err
+			}
+
+			editedAt := revision.CreatedAt
+			post.EditedAt = &editedAt
+		}
+
+		// Only the edited columns are written; counters, moderation settings and the like
+		// may have changed since the post was loaded
+		if err := tx.Model(&current).Updates(map[string]any{
+			"content":    post.Content,
+			"image":      post.Image,
+			"edited_at":  post.EditedAt,
+			"status":     post.Status,
+			"publish_at": post.PublishAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		replace := current.Status != model.PostStatusPublished
+		if err := referenceUploads(tx, model.UploadRefPost, post.ID, mediaUploads(post), replace); err != nil {
+			return err
+		}
+
+		if err := tx.Where("post_id = ?", post.ID).Delete(&model.PostMedia{}).Error; err != nil {
+			return err
+		}
+
+		if len(post.Media) == 0 {
+			return nil
+		}
+
+		for i := range post.Media {
+			post.Media[i].ID = uuid.Nil
+			post.Media[i].PostID = post.ID
+		}
+
+		return tx.Create(&post.Media).Error
+	})
+}
+
+// samePostContent reports whether an update leaves the visible content of a post as is
+func samePostContent(a, b *model.Post) bool {
+	if a.Content != b.Content || !equalStringPtr(a.Image, b.Image) || len(a.Media) != len(b.Media) {
+		return false
+	}
+	for i := range a.Media {
+		if a.Media[i].URL != b.Media[i].URL || !equalStringPtr(a.Media[i].AltText, b.Media[i].AltText) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// FindRevisions returns the previous versions of a post, newest first
+func (r *PostRepo) FindRevisions(postID uuid.UUID) ([]model.PostRevision, error) {
+	var revisions []model.PostRevision
+	err := r.db.Where("post_id = ?", postID).Order("version DESC").Find(&revisions).Error
+	return revisions, err
 }
 
 // Delete deletes a post from the database
@@ -61,6 +225,10 @@ func (r *PostRepo) Delete(id uuid.UUID) error {
 			tx.Rollback()
 			return err
 		}
+		if err := forgetEngagement(tx, *post.SharedPostID, post.UserID, model.EngagementShare, 1); err != nil {
+			tx.Rollback()
+			return err
+		}
 	}
 
 	// Find any posts that shared this one
@@ -70,11 +238,23 @@ func (r *PostRepo) Delete(id uuid.UUID) error {
 		return err
 	}
 
-	// Update sharing posts to mark the shared post as deleted or handle as needed
+	// Reposts have nothing left to show and go with the post; quotes keep their
+	// commentary and lose the reference
+	deletedIDs := []uuid.UUID{id}
 	for _, sharingPost := range sharingPosts {
-		// Option: You could set the shared_post_id to NULL to break the reference
-		// or you could add a flag indicating the original post was deleted
-		// Here we'll set shared_post_id to NULL
+		if sharingPost.ShareType == model.ShareTypeRepost {
+			deletedIDs = append(deletedIDs, sharingPost.ID)
+			if err := tx.Delete(&model.Post{}, "id = ?", sharingPost.ID).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+			if err := tx.Model(&model.User{}).Where("id = ?", sharingPost.UserID).Update("posts_count", gorm.Expr("posts_count - 1")).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+			continue
+		}
+
 		if err := tx.Model(&model.Post{}).Where("id = ?", sharingPost.ID).Update("shared_post_id", nil).Error; err != nil {
 			tx.Rollback()
 			return err
@@ -87,85 +267,267 @@ func (r *PostRepo) Delete(id uuid.UUID) error {
 		return err
 	}
 
-	// Decrement user's post count
-	if err := tx.Model(&model.User{}).Where("id = ?", post.UserID).Update("posts_count", gorm.Expr("posts_count - 1")).Error; err != nil {
+	if err := removeFromTimelines(tx, deletedIDs); err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	return tx.Commit().Error
-}
+	// Let the garbage collector reclaim the attachments
+	if err := releaseUploads(tx, model.UploadRefPost, deletedIDs); err != nil {
+		tx.Rollback()
+		return err
+	}
 
-// FindAll finds all posts with pagination and author preloaded
-func (r *PostRepo) FindAll(filter model.PostFilter) ([]model.Post, error) {
-	var posts []model.Post
-	query := r.db.Preload("Author").
-		Preload("SharedPost").
-		Preload("SharedPost.Author").
-		Order("created_at DESC").
-		Limit(filter.Limit).
-		Offset(filter.Offset)
+	// Decrement user's post count; drafts were never counted
+	if post.Status == model.PostStatusPublished {
+		if err := tx.Model(&model.User{}).Where("id = ?", post.UserID).Update("posts_count", gorm.Expr("posts_count - 1")).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
 
-	// Filter by user if userID is provided
-	if filter.UserID != "" {
-		userID, err := uuid.Parse(filter.UserID)
-		if err == nil {
-			query = query.Where("user_id = ?", userID)
+	// The post stays in its thread as a tombstone but no longer counts as a reply
+	if post.InReplyToID != nil && post.Status == model.PostStatusPublished {
+		if err := tx.Model(&model.Post{}).Unscoped().Where("id = ?", post.InReplyToID).Update("replies_count", gorm.Expr("replies_count - 1")).Error; err != nil {
+			tx.Rollback()
+			return err
 		}
 	}
 
-	err := query.Find(&posts).Error
-	return posts, err
+	return tx.Commit().Error
 }
 
-// FindFeed finds posts for a user's feed (posts from followed users and own posts)
-func (r *PostRepo) FindFeed(userID uuid.UUID, filter model.Pagination) ([]model.Post, error) {
-	var posts []model.Post
+// FindDraft finds a draft or scheduled post by ID
+func (r *PostRepo) FindDraft(id uuid.UUID) (*model.Post, error) {
+	var post model.Post
+	err := r.db.Scopes(withPostRelations).
+		Where("status IN ?", []model.PostStatus{model.PostStatusDraft, model.PostStatusScheduled}).
+		First(&post, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
 
-	// Get posts from followed users and own posts using a single join
-	err := r.db.Preload("Author").
-		Preload("SharedPost").
-		Preload("SharedPost.Author").
-		Distinct("posts.*").
-		Table("posts").
-		Joins("LEFT JOIN follows ON posts.user_id = follows.following_id AND follows.follower_id = ?", userID).
-		Where("follows.follower_id = ? OR posts.user_id = ?", userID, userID).
-		Order("posts.created_at DESC").
+	return &post, nil
+}
+
+// FindDrafts finds a user's drafts and scheduled posts, next scheduled first
+func (r *PostRepo) FindDrafts(userID uuid.UUID, filter model.Pagination) ([]model.Post, error) {
+	var posts []model.Post
+	err := r.db.Scopes(withPostRelations).
+		Where("user_id = ? AND status IN ?", userID, []model.PostStatus{model.PostStatusDraft, model.PostStatusScheduled}).
+		Order("publish_at ASC NULLS LAST, updated_at DESC").
 		Limit(filter.Limit).Offset(filter.Offset).
 		Find(&posts).Error
 
 	return posts, err
 }
 
-// FindTrending finds trending posts based on likes and comments count
-func (r *PostRepo) FindTrending(filter model.Pagination) ([]model.Post, error) {
-	var posts []model.Post
+// Publish publishes a draft or scheduled post and reports whether this call published it
+func (r *PostRepo) Publish(id uuid.UUID) (bool, error) {
+	published := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		published, err = publishPost(tx, &model.Post{ID: id})
+		return err
+	})
+	return published, err
+}
 
-	// Get posts ordered by engagement (likes + comments)
-	err := r.db.Preload("Author").
-		Preload("SharedPost").
-		Preload("SharedPost.Author").
-		Order("(likes_count + comments_count) DESC, created_at DESC").
-		Limit(filter.Limit).Offset(filter.Offset).
+// PublishDue publishes up to limit scheduled posts whose publish time has passed and
+// returns them. Due posts are locked with SKIP LOCKED and published with a conditional
+// update, so each post is published exactly once even with several schedulers running.
+func (r *PostRepo) PublishDue(now time.Time, limit int) ([]model.Post, error) {
+	var published []model.Post
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var due []model.Post
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND publish_at <= ?", model.PostStatusScheduled, now).
+			Order("publish_at ASC").
+			Limit(limit).
+			Find(&due).Error; err != nil {
+			return err
+		}
+
+		for i := range due {
+			ok, err := publishPost(tx, &due[i])
+			if err != nil {
+				return err
+			}
+			if ok {
+				published = append(published, due[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return published, nil
+}
+
+// publishPost marks an unpublished post as published and counts it for its author. A
+// post is dated from when it is published so that it appears at the top of feeds.
+func publishPost(tx *gorm.DB, post *model.Post) (bool, error) {
+	result := tx.Model(post).Clauses(clause.Returning{}).
+		Where("status <> ?", model.PostStatusPublished).
+		Updates(map[string]any{
+			"status":     model.PostStatusPublished,
+			"publish_at": nil,
+			"created_at": gorm.Expr("NOW()"),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	if err := tx.Model(&model.User{}).Where("id = ?", post.UserID).Update("posts_count", gorm.Expr("posts_count + 1")).Error; err != nil {
+		return false, err
+	}
+	return true, enqueueFanout(tx, post.ID)
+}
+
+const (
+	// maxThreadDepth bounds how far up a thread ancestors are followed
+	maxThreadDepth = 1000
+	// maxNestedReplies bounds how many replies are loaded below each nested reply; the
+	// rest are left to be fetched from that reply's own thread
+	maxNestedReplies = 5
+	// maxThreadReplies bounds the nested replies loaded for one page of a thread
+	maxThreadReplies = 500
+)
+
+// FindAncestors returns the posts a post replies to, root first, with deleted posts as
+// tombstones. With selfOnly the chain stops at the first post by another author.
+func (r *PostRepo) FindAncestors(post *model.Post, selfOnly bool) ([]model.Post, error) {
+	if post.InReplyToID == nil {
+		return []model.Post{}, nil
+	}
+
+	authorFilter := ""
+	if selfOnly {
+		authorFilter = " AND p.user_id = @author"
+	}
+
+	var ids []uuid.UUID
+	err := r.db.Raw(`WITH RECURSIVE ancestors AS (
+			SELECT p.id, p.in_reply_to_id, 1 AS depth FROM posts p WHERE p.id = @parent`+authorFilter+`
+			UNION ALL
+			SELECT p.id, p.in_reply_to_id, a.depth + 1 FROM posts p
+			JOIN ancestors a ON p.id = a.in_reply_to_id
+			WHERE a.depth < @maxDepth`+authorFilter+`
+		) SELECT id FROM ancestors ORDER BY depth DESC`,
+		sql.Named("parent", post.InReplyToID), sql.Named("author", post.UserID), sql.Named("maxDepth", maxThreadDepth)).
+		Scan(&ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return r.findThreadPosts(ids)
+}
+
+// FindReplies returns a page of the direct replies to a post, oldest first, together
+// with their own replies up to filter.Depth levels below the post. Below the page only
+// the oldest maxNestedReplies replies of each post are followed, up to maxThreadReplies
+// in total. Deleted replies are returned as tombstones. In self mode only replies by the
+// post's author are followed.
+func (r *PostRepo) FindReplies(post *model.Post, filter model.ThreadFilter) ([]model.Post, error) {
+	selfOnly := filter.Mode == model.ThreadModeSelf
+
+	// Deleted replies are only worth a page slot when something still hangs below them
+	query := r.db.Unscoped().Model(&model.Post{}).
+		Where("in_reply_to_id = ? AND status = ?", post.ID, model.PostStatusPublished).
+		Where("deleted_at IS NULL OR replies_count > 0")
+	if selfOnly {
+		query = query.Where("user_id = ?", post.UserID)
+	}
+
+	var ids []uuid.UUID
+	if err := query.Order("created_at ASC").Limit(filter.Limit).Offset(filter.Offset).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	if len(ids) > 0 && filter.Depth > 1 {
+		authorFilter := ""
+		if selfOnly {
+			authorFilter = " AND p.user_id = @author"
+		}
+
+		// The recursion is breadth first, so the total limit cuts off the deepest levels
+		nested := `SELECT p.id FROM posts p
+			WHERE p.in_reply_to_id = parent.id AND p.status = @published
+			AND (p.deleted_at IS NULL OR p.replies_count > 0)` + authorFilter + `
+			ORDER BY p.created_at ASC LIMIT @perParent`
+
+		var descendants []uuid.UUID
+		err := r.db.Raw(`WITH RECURSIVE descendants AS (
+				SELECT r.id, 2 AS depth FROM posts parent
+				CROSS JOIN LATERAL (`+nested+`) r
+				WHERE parent.id IN @children
+				UNION ALL
+				SELECT r.id, parent.depth + 1 FROM descendants parent
+				CROSS JOIN LATERAL (`+nested+`) r
+				WHERE parent.depth < @maxDepth
+			) SELECT id FROM descendants LIMIT @maxRows`,
+			sql.Named("children", ids), sql.Named("author", post.UserID), sql.Named("maxDepth", filter.Depth),
+			sql.Named("published", model.PostStatusPublished), sql.Named("perParent", maxNestedReplies),
+			sql.Named("maxRows", maxThreadReplies)).
+			Scan(&descendants).Error
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, descendants...)
+	}
+
+	return r.findThreadPosts(ids)
+}
+
+// findThreadPosts loads thread posts by ID, oldest first, turning deleted posts into
+// tombstones
+func (r *PostRepo) findThreadPosts(ids []uuid.UUID) ([]model.Post, error) {
+	posts := []model.Post{}
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	err := r.db.Unscoped().Scopes(withPostRelations, publishedPosts).
+		Where("posts.id IN ?", ids).
+		Order("posts.created_at ASC").
 		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
 
-	return posts, err
+	for i := range posts {
+		if posts[i].DeletedAt.Valid {
+			posts[i].Tombstone()
+		}
+	}
+
+	return posts, nil
 }
 
-// SearchPosts searches posts by content
-func (r *PostRepo) SearchPosts(query string, filter model.Pagination) ([]model.Post, error) {
-	var posts []model.Post
+// FindAll finds a page of posts, newest first, with author preloaded
+func (r *PostRepo) FindAll(filter model.PostFilter) ([]model.Post, util.CursorMeta, error) {
+	query := r.db.Scopes(withPostRelations, publishedPosts)
+
+	// Filter by user if userID is provided
+	if filter.UserID != "" {
+		userID, err := uuid.Parse(filter.UserID)
+		if err == nil {
+			query = query.Where("user_id = ?", userID)
+		}
+	}
+
+	return findPage(query, newestPosts, filter.Pagination, postPosition)
+}
 
+// SearchPosts searches posts by content
+func (r *PostRepo) SearchPosts(query string, filter model.Pagination) ([]model.Post, util.CursorMeta, error) {
 	// Search posts by content using ILIKE for case-insensitive search
-	err := r.db.Preload("Author").
-		Preload("SharedPost").
-		Preload("SharedPost.Author").
-		Where("content ILIKE ?", "%"+query+"%").
-		Order("created_at DESC").
-		Limit(filter.Limit).Offset(filter.Offset).
-		Find(&posts).Error
+	search := r.db.Scopes(withPostRelations, publishedPosts).
+		Where("content ILIKE ?", "%"+query+"%")
 
-	return posts, err
+	return findPage(search, newestPosts, filter, postPosition)
 }
 
 // Like adds a like to a post
@@ -177,8 +539,9 @@ func (r *PostRepo) Like(userID, postID uuid.UUID) (int, error) {
 	}
 
 	like := model.Like{
-		UserID: userID,
-		PostID: postID,
+		UserID:   userID,
+		PostID:   postID,
+		Reaction: model.ReactionLike,
 	}
 
 	// Create like
@@ -187,9 +550,13 @@ func (r *PostRepo) Like(userID, postID uuid.UUID) (int, error) {
 		return 0, err
 	}
 
-	var updatedPost model.Post
-	// Increment post's likes_count
-	if err := tx.Model(&updatedPost).Clauses(clause.Returning{}).Where("id = ?", postID).Update("likes_count", gorm.Expr("likes_count + 1")).Error; err != nil {
+	if err := recordEngagement(tx, postID, userID, model.EngagementReaction); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	updatedPost, err := countReaction(tx, postID, like.Reaction, 1)
+	if err != nil {
 		tx.Rollback()
 		return 0, err
 	}
@@ -197,32 +564,117 @@ func (r *PostRepo) Like(userID, postID uuid.UUID) (int, error) {
 	return updatedPost.LikesCount, tx.Commit().Error
 }
 
-// Unlike removes a like from a post
+// Unlike removes a like, or any other reaction, from a post
 func (r *PostRepo) Unlike(userID, postID uuid.UUID) (int, error) {
-	// Use transaction to handle like removal and counter update
-	tx := r.db.Begin()
-	if tx.Error != nil {
-		return 0, tx.Error
+	post, err := r.Unreact(userID, postID)
+	if err != nil {
+		return 0, err
 	}
+	return post.LikesCount, nil
+}
 
-	// Delete like
-	result := tx.Where("user_id = ? AND post_id = ?", userID, postID).Delete(&model.Like{})
-	if result.Error != nil {
-		tx.Rollback()
-		return 0, result.Error
+// React sets a user's reaction to a post, replacing any reaction they left before, and
+// returns the post with updated counters
+func (r *PostRepo) React(userID, postID uuid.UUID, reaction string) (*model.Post, error) {
+	var updatedPost *model.Post
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var current model.Like
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND post_id = ?", userID, postID).
+			Limit(1).Find(&current)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var err error
+		switch {
+		case result.RowsAffected == 0:
+			like := model.Like{UserID: userID, PostID: postID, Reaction: reaction}
+			if err := tx.Create(&like).Error; err != nil {
+				return err
+			}
+			if err := recordEngagement(tx, postID, userID, model.EngagementReaction); err != nil {
+				return err
+			}
+			updatedPost, err = countReaction(tx, postID, reaction, 1)
+		case current.Reaction == reaction:
+			updatedPost = &model.Post{}
+			err = tx.Select("id", "likes_count", "reactions").First(updatedPost, "id = ?", postID).Error
+		default:
+			if err := tx.Model(&current).Update("reaction", reaction).Error; err != nil {
+				return err
+			}
+			if _, err := countReaction(tx, postID, current.Reaction, -1); err != nil {
+				return err
+			}
+			// Moving the reaction leaves the total unchanged
+			updatedPost, err = countReaction(tx, postID, reaction, 1)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if result.RowsAffected == 0 {
-		tx.Rollback()
-		return 0, errors.New("like not found")
+	return updatedPost, nil
+}
+
+// Unreact removes a user's reaction from a post and returns the post with updated
+// counters
+func (r *PostRepo) Unreact(userID, postID uuid.UUID) (*model.Post, error) {
+	var updatedPost *model.Post
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var like model.Like
+		result := tx.Clauses(clause.Returning{}).Where("user_id = ? AND post_id = ?", userID, postID).Delete(&like)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errors.New("like not found")
+		}
+
+		if err := forgetEngagement(tx, postID, userID, model.EngagementReaction, 1); err != nil {
+			return err
+		}
+
+		var err error
+		updatedPost, err = countReaction(tx, postID, like.Reaction, -1)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	return updatedPost, nil
+}
+
+// countReaction adds delta to the total and per reaction counters of a post. Reactions
+// whose count drops to zero are removed from the breakdown.
+func countReaction(tx *gorm.DB, postID uuid.UUID, reaction string, delta int) (*model.Post, error) {
 	var updatedPost model.Post
-	if err := tx.Model(&updatedPost).Clauses(clause.Returning{}).Where("id = ?", postID).Update("likes_count", gorm.Expr("likes_count - 1")).Error; err != nil {
-		tx.Rollback()
-		return 0, err
+	err := tx.Model(&updatedPost).Clauses(clause.Returning{}).Where("id = ?", postID).Updates(map[string]any{
+		"likes_count": gorm.Expr("likes_count + ?", delta),
+		"reactions": gorm.Expr("jsonb_strip_nulls(COALESCE(reactions, '{}'::jsonb) || jsonb_build_object(?::text, NULLIF(COALESCE((reactions->>?)::int, 0) + ?, 0)))",
+			reaction, reaction, delta),
+	}).Error
+	if err != nil {
+		return nil, err
 	}
+	return &updatedPost, nil
+}
 
-	return updatedPost.LikesCount, tx.Commit().Error
+// FindReactions returns the reactions to a post, newest first, with the users who left
+// them. An empty reaction filter lists every reaction.
+func (r *PostRepo) FindReactions(postID uuid.UUID, filter model.ReactionFilter) ([]model.Like, util.CursorMeta, error) {
+	query := r.db.Preload("User").Where("post_id = ?", postID)
+	if filter.Reaction != "" {
+		query = query.Where("reaction = ?", filter.Reaction)
+	}
+
+	return findPage(query, newestLikes, filter.Pagination, func(like *model.Like) util.Cursor {
+		return util.Cursor{CreatedAt: like.CreatedAt, ID: like.UserID}
+	})
 }
 
 // IsLiked checks if a post is liked by a user
@@ -249,14 +701,17 @@ func (r *PostRepo) FillLikeInfo(userID *uuid.UUID, posts []model.Post) ([]model.
 		return nil, err
 	}
 
-	likedMap := make(map[uuid.UUID]bool)
+	reactions := make(map[uuid.UUID]string)
 	for _, like := range likes {
-		likedMap[like.PostID] = true
+		reactions[like.PostID] = like.Reaction
 	}
 
 	for i := range posts {
-		isLiked := likedMap[posts[i].ID]
+		reaction, isLiked := reactions[posts[i].ID]
 		posts[i].IsLiked = &isLiked
+		if isLiked {
+			posts[i].Reaction = &reaction
+		}
 	}
 
 	return posts, nil
@@ -271,8 +726,10 @@ func (r *PostRepo) CountLikes(postID uuid.UUID) (int, error) {
 	return post.LikesCount, nil
 }
 
-// Share creates a new post that shares an existing post
-func (r *PostRepo) Share(userID, postID uuid.UUID, content string) (*model.Post, error) {
+// Share creates a new post that shares an existing post. A user can repost a post only
+// once, while quotes add commentary and can be repeated. Sharing a repost shares the
+// post it reposted.
+func (r *PostRepo) Share(userID, postID uuid.UUID, shareType model.ShareType, content string) (*model.Post, error) {
 	// Use transaction to handle share creation
 	tx := r.db.Begin()
 	if tx.Error != nil {
@@ -281,34 +738,65 @@ func (r *PostRepo) Share(userID, postID uuid.UUID, content string) (*model.Post,
 
 	// Get original post
 	var originalPost model.Post
-	if err := tx.Where("id = ?", postID).First(&originalPost).Error; err != nil {
+	if err := tx.Scopes(publishedPosts).Where("id = ?", postID).First(&originalPost).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	// Create new post as a share
+	if originalPost.ShareType == model.ShareTypeRepost && originalPost.SharedPostID != nil {
+		if err := tx.Scopes(publishedPosts).Where("id = ?", originalPost.SharedPostID).First(&originalPost).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if shareType == model.ShareTypeRepost {
+		content = ""
+
+		// Lock the original post so concurrent reposts by the same user are serialized
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&model.Post{}, "id = ?", originalPost.ID).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		reposted, err := isReposted(tx, userID, originalPost.ID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if reposted {
+			tx.Rollback()
+			return nil, ErrAlreadyReposted
+		}
+	}
+
+	// Create new post as a share; creating it counts it for the user
 	newPost := model.Post{
 		ID:           uuid.New(),
 		UserID:       userID,
 		Content:      content,
 		SharedPostID: &originalPost.ID,
-		SharedPost:   &originalPost,
+		ShareType:    shareType,
 	}
 
 	// Save new post
-	if err := tx.Create(&newPost).Error; err != nil {
+	if err := tx.Omit(clause.Associations).Create(&newPost).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	// Increment original post's shares_count
-	if err := tx.Model(&model.Post{}).Where("id = ?", postID).Update("shares_count", gorm.Expr("shares_count + 1")).Error; err != nil {
+	if err := enqueueFanout(tx, newPost.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := recordEngagement(tx, originalPost.ID, userID, model.EngagementShare); err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	// Increment user's post count
-	if err := tx.Model(&model.User{}).Where("id = ?", userID).Update("posts_count", gorm.Expr("posts_count + 1")).Error; err != nil {
+	// Increment original post's shares_count
+	if err := tx.Model(&model.Post{}).Where("id = ?", originalPost.ID).Update("shares_count", gorm.Expr("shares_count + 1")).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
@@ -319,22 +807,114 @@ func (r *PostRepo) Share(userID, postID uuid.UUID, content string) (*model.Post,
 
 	// Reload the post with author information
 	var post model.Post
-	if err := r.db.Preload("Author").Preload("SharedPost").Preload("SharedPost.Author").Where("id = ?", newPost.ID).First(&post).Error; err != nil {
+	if err := r.db.Scopes(withPostRelations).Where("id = ?", newPost.ID).First(&post).Error; err != nil {
 		return nil, err
 	}
 
 	return &post, nil
 }
 
+// Unrepost undoes a user's repost of a post
+func (r *PostRepo) Unrepost(userID, postID uuid.UUID) error {
+	var repost model.Post
+	err := r.db.Where("user_id = ? AND shared_post_id = ? AND share_type = ?", userID, postID, model.ShareTypeRepost).
+		First(&repost).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotReposted
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.Delete(repost.ID)
+}
+
+// isReposted checks if a post is reposted by a user
+func isReposted(db *gorm.DB, userID, postID uuid.UUID) (bool, error) {
+	var count int64
+	err := db.Model(&model.Post{}).
+		Where("user_id = ? AND shared_post_id = ? AND share_type = ?", userID, postID, model.ShareTypeRepost).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// FillRepostInfo sets whether the user reposted each of the given posts
+func (r *PostRepo) FillRepostInfo(userID *uuid.UUID, posts []model.Post) ([]model.Post, error) {
+	if userID == nil || len(posts) == 0 {
+		return posts, nil
+	}
+
+	postIDs := make([]uuid.UUID, len(posts))
+	for i := range posts {
+		postIDs[i] = posts[i].ID
+	}
+
+	var reposted []uuid.UUID
+	err := r.db.Model(&model.Post{}).
+		Where("user_id = ? AND share_type = ? AND shared_post_id IN ?", userID, model.ShareTypeRepost, postIDs).
+		Pluck("shared_post_id", &reposted).Error
+	if err != nil {
+		return nil, err
+	}
+
+	repostedMap := make(map[uuid.UUID]bool, len(reposted))
+	for _, id := range reposted {
+		repostedMap[id] = true
+	}
+
+	for i := range posts {
+		isReposted := repostedMap[posts[i].ID]
+		posts[i].IsReposted = &isReposted
+	}
+
+	return posts, nil
+}
+
+// FindReposters returns the users who reposted a post, most recent first
+func (r *PostRepo) FindReposters(postID uuid.UUID, filter model.Pagination) ([]model.User, util.CursorMeta, error) {
+	query := r.db.Preload("Author").
+		Where("posts.shared_post_id = ? AND posts.share_type = ?", postID, model.ShareTypeRepost)
+
+	reposts, meta, err := findPage(query, newestPosts, filter, postPosition)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	users := make([]model.User, 0, len(reposts))
+	for _, repost := range reposts {
+		if repost.Author != nil {
+			users = append(users, *repost.Author)
+		}
+	}
+	return users, meta, nil
+}
+
+// FindQuotes returns the posts quoting a post, newest first
+func (r *PostRepo) FindQuotes(postID uuid.UUID, filter model.Pagination) ([]model.Post, util.CursorMeta, error) {
+	query := r.db.Scopes(withPostRelations, publishedPosts).
+		Where("shared_post_id = ? AND share_type = ?", postID, model.ShareTypeQuote)
+
+	return findPage(query, newestPosts, filter, postPosition)
+}
+
+// SetCommentPolicy changes who may comment on a post
+func (r *PostRepo) SetCommentPolicy(id uuid.UUID, policy model.CommentPolicy) error {
+	return r.db.Model(&model.Post{}).Where("id = ?", id).Update("comment_policy", policy).Error
+}
+
+// SetPinnedComment pins a comment to the top of a post's comments, or unpins it when
+// commentID is nil
+func (r *PostRepo) SetPinnedComment(id uuid.UUID, commentID *uuid.UUID) error {
+	return r.db.Model(&model.Post{}).Where("id = ?", id).Update("pinned_comment_id", commentID).Error
+}
+
 // GetSuggestedPosts returns posts that might interest the user
 func (r *PostRepo) GetSuggestedPosts(userID uuid.UUID, filter model.Pagination) ([]model.Post, error) {
 	var posts []model.Post
 
 	// Get posts from users that are followed by users that the current user follows
 	// This is a "friends of friends" approach
-	err := r.db.Preload("Author").
-		Preload("SharedPost").
-		Preload("SharedPost.Author").
+	err := r.db.Scopes(withPostRelations).
 		Distinct("posts.*").
 		Table("posts").
 		Joins("JOIN users u ON posts.user_id = u.id").
@@ -342,13 +922,15 @@ func (r *PostRepo) GetSuggestedPosts(userID uuid.UUID, filter model.Pagination)
 		Joins("JOIN follows f2 ON f2.follower_id = f1.following_id AND f2.following_id != ?", userID).
 		Where("f1.follower_id = ? AND posts.user_id != ?", userID, userID).
 		Where("NOT EXISTS (SELECT 1 FROM follows WHERE follower_id = ? AND following_id = posts.user_id)", userID).
+		Scopes(publishedPosts).
 		Order("posts.created_at DESC").
 		Limit(filter.Limit).Offset(filter.Offset).
 		Find(&posts).Error
 
-	// If no posts found through network connections, return trending posts
+	// If no posts found through network connections, return this week's trending posts
 	if len(posts) == 0 {
-		return r.FindTrending(filter)
+		filter.Cursor = ""
+		posts, _, err = findTrending(r.db, "7d", filter)
 	}
 
 	return posts, err
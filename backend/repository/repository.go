@@ -4,6 +4,8 @@ import (
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"socialnet/model"
+	"socialnet/util"
+	"time"
 )
 
 // UserRepository handles database operations related to users
@@ -18,8 +20,8 @@ type UserRepository interface {
 	Unfollow(followerID, followingID uuid.UUID) error
 	IsFollowing(followerID uuid.UUID, following uuid.UUID) (bool, error)
 	FillFollowingInfo(currentUserID *uuid.UUID, listUsers []model.User) ([]model.User, error)
-	GetFollowers(userID uuid.UUID, filter model.FollowFilter) ([]model.User, error)
-	GetFollowing(userID uuid.UUID, filter model.FollowFilter) ([]model.User, error)
+	GetFollowers(userID uuid.UUID, filter model.FollowFilter) ([]model.User, util.CursorMeta, error)
+	GetFollowing(userID uuid.UUID, filter model.FollowFilter) ([]model.User, util.CursorMeta, error)
 	CountFollowers(userID uuid.UUID) (int, error)
 	CountFollowing(userID uuid.UUID) (int, error)
 	SearchUsers(query string, filter model.Pagination) ([]model.User, error)
@@ -34,27 +36,49 @@ type PostRepository interface {
 	Create(post *model.Post) error
 	FindByID(id uuid.UUID) (*model.Post, error)
 	Update(post *model.Post) error
+	FindRevisions(postID uuid.UUID) ([]model.PostRevision, error)
 	Delete(id uuid.UUID) error
-	FindAll(filter model.PostFilter) ([]model.Post, error)
-	FindFeed(userID uuid.UUID, filter model.Pagination) ([]model.Post, error)
-	FindTrending(filter model.Pagination) ([]model.Post, error)
-	SearchPosts(query string, filter model.Pagination) ([]model.Post, error)
+	FindDraft(id uuid.UUID) (*model.Post, error)
+	FindDrafts(userID uuid.UUID, filter model.Pagination) ([]model.Post, error)
+	Publish(id uuid.UUID) (bool, error)
+	PublishDue(now time.Time, limit int) ([]model.Post, error)
+	FindAncestors(post *model.Post, selfOnly bool) ([]model.Post, error)
+	FindReplies(post *model.Post, filter model.ThreadFilter) ([]model.Post, error)
+	FindAll(filter model.PostFilter) ([]model.Post, util.CursorMeta, error)
+	SearchPosts(query string, filter model.Pagination) ([]model.Post, util.CursorMeta, error)
 	Like(userID, postID uuid.UUID) (int, error)
 	Unlike(userID, postID uuid.UUID) (int, error)
+	React(userID, postID uuid.UUID, reaction string) (*model.Post, error)
+	Unreact(userID, postID uuid.UUID) (*model.Post, error)
+	FindReactions(postID uuid.UUID, filter model.ReactionFilter) ([]model.Like, util.CursorMeta, error)
 	IsLiked(userID, postID uuid.UUID) (bool, error)
 	FillLikeInfo(userID *uuid.UUID, posts []model.Post) ([]model.Post, error)
 	CountLikes(postID uuid.UUID) (int, error)
-	Share(userID, postID uuid.UUID, content string) (*model.Post, error)
+	Share(userID, postID uuid.UUID, shareType model.ShareType, content string) (*model.Post, error)
+	Unrepost(userID, postID uuid.UUID) error
+	FillRepostInfo(userID *uuid.UUID, posts []model.Post) ([]model.Post, error)
+	FindReposters(postID uuid.UUID, filter model.Pagination) ([]model.User, util.CursorMeta, error)
+	FindQuotes(postID uuid.UUID, filter model.Pagination) ([]model.Post, util.CursorMeta, error)
 	GetSuggestedPosts(userID uuid.UUID, filter model.Pagination) ([]model.Post, error)
+	SetCommentPolicy(id uuid.UUID, policy model.CommentPolicy) error
+	SetPinnedComment(id uuid.UUID, commentID *uuid.UUID) error
 }
 
 // CommentRepository handles database operations related to comments
 type CommentRepository interface {
 	Create(comment *model.Comment) error
 	FindByID(id uuid.UUID) (*model.Comment, error)
+	FindVisibleByID(id uuid.UUID, viewerID *uuid.UUID) (*model.Comment, error)
 	Update(comment *model.Comment) error
+	FindRevisions(commentID uuid.UUID) ([]model.CommentRevision, error)
 	Delete(id uuid.UUID) error
-	FindByPostID(postID uuid.UUID, filter model.Pagination) ([]model.Comment, error)
+	FindByPostID(postID uuid.UUID, viewerID *uuid.UUID, filter model.CommentListFilter) ([]model.Comment, util.CursorMeta, error)
+	FindReplies(commentID uuid.UUID, viewerID *uuid.UUID, filter model.Pagination) ([]model.Comment, util.CursorMeta, error)
+	SetHidden(comment *model.Comment, hidden bool) error
+	Like(userID, commentID uuid.UUID) (int, error)
+	Unlike(userID, commentID uuid.UUID) (int, error)
+	IsLiked(userID, commentID uuid.UUID) (bool, error)
+	FillLikeInfo(userID *uuid.UUID, comments []model.Comment) ([]model.Comment, error)
 }
 
 // Repository holds all repositories
@@ -64,6 +88,11 @@ type Repository struct {
 	Comment      CommentRepository
 	Message      *MessageRepository
 	Notification *NotificationRepository
+	Upload       *UploadRepository
+	Poll         *PollRepository
+	Bookmark     *BookmarkRepository
+	Timeline     *TimelineRepository
+	Trending     *TrendingRepository
 }
 
 // NewRepository creates a new Repository
@@ -74,5 +103,10 @@ func NewRepository(db *gorm.DB) *Repository {
 		Comment:      NewCommentRepository(db),
 		Message:      NewMessageRepository(db),
 		Notification: NewNotificationRepository(db),
+		Upload:       NewUploadRepository(db),
+		Poll:         NewPollRepository(db),
+		Bookmark:     NewBookmarkRepository(db),
+		Timeline:     NewTimelineRepository(db),
+		Trending:     NewTrendingRepository(db),
 	}
 }
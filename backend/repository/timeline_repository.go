@@ -0,0 +1,404 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"socialnet/model"
+	"socialnet/util"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rebuildBatchSize is the number of users whose timelines are rebuilt per transaction
+const rebuildBatchSize = 500
+
+// followerBatchSize is the number of users looked up per follower query
+const followerBatchSize = 1000
+
+// newPostsChannel is the Postgres notification channel fanned out posts are announced on
+const newPostsChannel = "new_posts"
+
+// noticeBatchSize is the number of posts announced per notification; payloads must stay
+// under Postgres' 8000 byte limit
+const noticeBatchSize = 50
+
+// backfillTimelines copies the latest posts of followed accounts to the timelines of
+// the follows matched by filter. Accounts above the pull threshold are skipped.
+const backfillTimelines = `
+INSERT INTO timeline_entries (user_id, post_id, author_id, created_at)
+SELECT f.follower_id, p.id, p.user_id, p.created_at
+FROM follows f
+JOIN users u ON u.id = f.following_id AND u.followers_count <= @threshold
+CROSS JOIN LATERAL (
+	SELECT posts.id, posts.user_id, posts.created_at FROM posts
+	WHERE posts.user_id = f.following_id AND posts.status = 'published' AND posts.deleted_at IS NULL
+	ORDER BY posts.created_at DESC
+	LIMIT @size
+) p
+WHERE %s
+ON CONFLICT DO NOTHING`
+
+// TimelineRepository handles database operations for materialized home timelines
+type TimelineRepository struct {
+	db *gorm.DB
+}
+
+// NewTimelineRepository creates a new TimelineRepository
+func NewTimelineRepository(db *gorm.DB) *TimelineRepository {
+	return &TimelineRepository{db}
+}
+
+// enqueueFanout queues a newly published post for fan-out in the transaction that
+// publishes it
+func enqueueFanout(tx *gorm.DB, postID uuid.UUID) error {
+	return tx.Create(&model.FanoutJob{PostID: postID}).Error
+}
+
+// timelineSources lists the posts on a user's home timeline as (id, author_id,
+// created_at) rows: the entries fanned out to them, their own posts and the posts of
+// followed accounts above the pull threshold. Each branch is read newest first from an
+// index, so a page only touches the rows it returns. A followed account below the
+// threshold is read from the posts table too while none of its posts are on the
+// timeline, as for follows made before timelines were introduced.
+const timelineSources = `
+SELECT timeline_entries.post_id AS id, timeline_entries.author_id, timeline_entries.created_at
+FROM timeline_entries
+WHERE timeline_entries.user_id = @user
+UNION ALL
+SELECT posts.id, posts.user_id, posts.created_at FROM posts
+WHERE posts.user_id = @user AND posts.status = @published AND posts.deleted_at IS NULL
+UNION ALL
+SELECT posts.id, posts.user_id, posts.created_at FROM follows
+JOIN users ON users.id = follows.following_id
+JOIN posts ON posts.user_id = follows.following_id
+WHERE follows.follower_id = @user
+	AND (users.followers_count > @threshold
+		OR NOT EXISTS (SELECT 1 FROM timeline_entries
+			WHERE timeline_entries.user_id = @user AND timeline_entries.author_id = follows.following_id))
+	AND posts.status = @published AND posts.deleted_at IS NULL
+	AND NOT EXISTS (SELECT 1 FROM timeline_entries
+		WHERE timeline_entries.user_id = @user AND timeline_entries.post_id = posts.id)`
+
+// timelineItem is a post on a home timeline before its content is loaded
+type timelineItem struct {
+	ID        uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
+}
+
+// newestTimeline orders a home timeline; it shares its cursors with newestPosts
+var newestTimeline = keyset{sort: newestPosts.sort, createdAt: "timeline.created_at", id: "timeline.id"}
+
+// timeline selects the posts on a user's home timeline from a table aliased timeline
+func (r *TimelineRepository) timeline(userID uuid.UUID, pullThreshold int) *gorm.DB {
+	sources := r.db.Raw(timelineSources, sql.Named("user", userID),
+		sql.Named("threshold", pullThreshold), sql.Named("published", model.PostStatusPublished))
+	return r.db.Table("(?) AS timeline", sources)
+}
+
+// FindFeed finds a page of a user's home timeline, newest first
+func (r *TimelineRepository) FindFeed(userID uuid.UUID, pullThreshold int, filter model.Pagination) ([]model.Post, util.CursorMeta, error) {
+	items, meta, err := findPage(r.timeline(userID, pullThreshold), newestTimeline, filter, func(item *timelineItem) util.Cursor {
+		return util.Cursor{CreatedAt: item.CreatedAt, ID: item.ID}
+	})
+	if err != nil {
+		return nil, meta, err
+	}
+
+	ids := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	posts, err := r.FindPosts(ids)
+	return posts, meta, err
+}
+
+// CountNew counts the posts by other accounts on a user's home timeline created after
+// the given time
+func (r *TimelineRepository) CountNew(userID uuid.UUID, pullThreshold int, since time.Time) (int64, error) {
+	var count int64
+	err := r.timeline(userID, pullThreshold).
+		Where("timeline.author_id <> ? AND timeline.created_at > ?", userID, since).
+		Count(&count).Error
+
+	return count, err
+}
+
+// FindCandidates returns up to limit of the newest posts on a user's home timeline
+// created after since, without their relations, to be ranked
+func (r *TimelineRepository) FindCandidates(userID uuid.UUID, pullThreshold int, since time.Time, limit int) ([]model.Post, error) {
+	newest := r.timeline(userID, pullThreshold).
+		Select("timeline.id").
+		Where("timeline.created_at > ?", since).
+		Order("timeline.created_at DESC").
+		Limit(limit)
+
+	var posts []model.Post
+	err := r.db.Scopes(publishedPosts).
+		Select("posts.id, posts.user_id, posts.created_at, posts.likes_count, posts.comments_count, posts.shares_count").
+		Where("posts.id IN (?)", newest).
+		Order("posts.created_at DESC").
+		Find(&posts).Error
+
+	return posts, err
+}
+
+// FindAffinity counts, for each of the given authors, the likes and comments a user
+// left on their posts since the given time
+func (r *TimelineRepository) FindAffinity(userID uuid.UUID, authorIDs []uuid.UUID, since time.Time) (map[uuid.UUID]int, error) {
+	affinity := make(map[uuid.UUID]int, len(authorIDs))
+	if len(authorIDs) == 0 {
+		return affinity, nil
+	}
+
+	var counts []struct {
+		AuthorID uuid.UUID
+		Count    int
+	}
+	err := r.db.Raw(`
+		SELECT author_id, COUNT(*) AS count FROM (
+			SELECT posts.user_id AS author_id FROM likes
+			JOIN posts ON posts.id = likes.post_id
+			WHERE likes.user_id = @user AND likes.created_at > @since AND posts.user_id IN @authors
+			UNION ALL
+			SELECT posts.user_id FROM comments
+			JOIN posts ON posts.id = comments.post_id
+			WHERE comments.user_id = @user AND comments.created_at > @since AND comments.deleted_at IS NULL
+				AND posts.user_id IN @authors
+		) interactions
+		GROUP BY author_id`,
+		sql.Named("user", userID), sql.Named("since", since), sql.Named("authors", authorIDs)).
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, count := range counts {
+		affinity[count.AuthorID] = count.Count
+	}
+	return affinity, nil
+}
+
+// FindPosts loads published posts with their relations in the order of the given IDs.
+// Posts deleted since the IDs were read are left out.
+func (r *TimelineRepository) FindPosts(ids []uuid.UUID) ([]model.Post, error) {
+	var found []model.Post
+	if err := r.db.Scopes(withPostRelations, publishedPosts).Where("posts.id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]model.Post, len(found))
+	for _, post := range found {
+		byID[post.ID] = post
+	}
+
+	posts := make([]model.Post, 0, len(found))
+	for _, id := range ids {
+		if post, ok := byID[id]; ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+// FanOut copies up to limit queued posts to the timelines of their authors' followers
+// and returns how many jobs were done. Jobs are claimed with SKIP LOCKED, so several
+// workers can share the queue. Posts deleted in the meantime are dropped, and posts by
+// accounts above the pull threshold are left to be read at request time. Every post
+// still published is announced to the instances' live feeds.
+func (r *TimelineRepository) FanOut(limit, pullThreshold int) (int, error) {
+	done := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var jobs []model.FanoutJob
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Order("created_at ASC").
+			Limit(limit).
+			Find(&jobs).Error; err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+
+		jobIDs := make([]uuid.UUID, len(jobs))
+		postIDs := make([]uuid.UUID, len(jobs))
+		for i, job := range jobs {
+			jobIDs[i] = job.ID
+			postIDs[i] = job.PostID
+		}
+
+		var posts []model.Post
+		if err := tx.Scopes(publishedPosts).
+			Select("posts.id, posts.user_id, posts.created_at").
+			Where("posts.id IN ?", postIDs).
+			Order("posts.created_at ASC").
+			Find(&posts).Error; err != nil {
+			return err
+		}
+
+		if len(posts) > 0 {
+			ids := make([]uuid.UUID, len(posts))
+			for i, post := range posts {
+				ids[i] = post.ID
+			}
+
+			if err := tx.Exec(`
+				INSERT INTO timeline_entries (user_id, post_id, author_id, created_at)
+				SELECT follows.follower_id, posts.id, posts.user_id, posts.created_at
+				FROM posts
+				JOIN users ON users.id = posts.user_id AND users.followers_count <= ?
+				JOIN follows ON follows.following_id = posts.user_id
+				WHERE posts.id IN ?
+				ON CONFLICT DO NOTHING`,
+				pullThreshold, ids).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := announceNewPosts(tx, posts); err != nil {
+			return err
+		}
+
+		done = len(jobs)
+		return tx.Delete(&model.FanoutJob{}, "id IN ?", jobIDs).Error
+	})
+	return done, err
+}
+
+// announceNewPosts notifies every server instance listening with ListenNewPosts of the
+// fanned out posts once the transaction commits
+func announceNewPosts(tx *gorm.DB, posts []model.Post) error {
+	for start := 0; start < len(posts); start += noticeBatchSize {
+		batch := posts[start:min(start+noticeBatchSize, len(posts))]
+
+		notices := make([]model.NewPostNotice, len(batch))
+		for i, post := range batch {
+			notices[i] = model.NewPostNotice{PostID: post.ID, AuthorID: post.UserID}
+		}
+		payload, err := json.Marshal(notices)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Exec("SELECT pg_notify(?, ?)", newPostsChannel, string(payload)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListenNewPosts holds a database connection listening for fanned out posts, whichever
+// instance fanned them out, and passes each announced batch to handle. It returns when
+// the context is cancelled, the connection fails or a notification can't be read; the
+// connection is then discarded so that it doesn't go back to the pool still listening.
+func (r *TimelineRepository) ListenNewPosts(ctx context.Context, handle func([]model.NewPostNotice)) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pgxConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("listening for notifications needs a pgx connection")
+		}
+
+		if _, err := pgxConn.Conn().Exec(ctx, "LISTEN "+newPostsChannel); err != nil {
+			return errors.Join(err, driver.ErrBadConn)
+		}
+
+		for {
+			notification, err := pgxConn.Conn().WaitForNotification(ctx)
+			if err != nil {
+				return errors.Join(err, driver.ErrBadConn)
+			}
+
+			var notices []model.NewPostNotice
+			if err := json.Unmarshal([]byte(notification.Payload), &notices); err != nil {
+				return errors.Join(fmt.Errorf("malformed new post notification: %w", err), driver.ErrBadConn)
+			}
+			handle(notices)
+		}
+	})
+}
+
+// FindFollowers returns which of the given users follow which of the given accounts
+func (r *TimelineRepository) FindFollowers(followingIDs, userIDs []uuid.UUID) ([]model.Follow, error) {
+	var follows []model.Follow
+	for start := 0; start < len(userIDs); start += followerBatchSize {
+		batch := userIDs[start:min(start+followerBatchSize, len(userIDs))]
+
+		var found []model.Follow
+		if err := r.db.Where("following_id IN ? AND follower_id IN ?", followingIDs, batch).
+			Find(&found).Error; err != nil {
+			return nil, err
+		}
+		follows = append(follows, found...)
+	}
+	return follows, nil
+}
+
+// Backfill puts the latest posts of a newly followed account on the follower's timeline
+func (r *TimelineRepository) Backfill(followerID, followingID uuid.UUID, size, pullThreshold int) error {
+	return r.db.Exec(fmt.Sprintf(backfillTimelines, "f.follower_id = @follower AND f.following_id = @following"),
+		sql.Named("threshold", pullThreshold), sql.Named("size", size),
+		sql.Named("follower", followerID), sql.Named("following", followingID)).Error
+}
+
+// Purge takes an account's posts off a user's timeline, as when the user unfollows it
+func (r *TimelineRepository) Purge(userID, authorID uuid.UUID) error {
+	return r.db.Where("user_id = ? AND author_id = ?", userID, authorID).Delete(&model.TimelineEntry{}).Error
+}
+
+// removeFromTimelines takes deleted posts off every timeline
+func removeFromTimelines(tx *gorm.DB, postIDs []uuid.UUID) error {
+	return tx.Where("post_id IN ?", postIDs).Delete(&model.TimelineEntry{}).Error
+}
+
+// Rebuild replaces every user's timeline with the latest size posts of each account
+// they follow and returns how many timelines were rebuilt
+func (r *TimelineRepository) Rebuild(size, pullThreshold int) (int, error) {
+	total := 0
+	after := uuid.Nil
+	for {
+		var userIDs []uuid.UUID
+		if err := r.db.Model(&model.User{}).Where("id > ?", after).
+			Order("id ASC").Limit(rebuildBatchSize).
+			Pluck("id", &userIDs).Error; err != nil {
+			return total, err
+		}
+		if len(userIDs) == 0 {
+			return total, nil
+		}
+
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id IN ?", userIDs).Delete(&model.TimelineEntry{}).Error; err != nil {
+				return err
+			}
+			return tx.Exec(fmt.Sprintf(backfillTimelines, "f.follower_id IN @users"),
+				sql.Named("threshold", pullThreshold), sql.Named("size", size),
+				sql.Named("users", userIDs)).Error
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += len(userIDs)
+		after = userIDs[len(userIDs)-1]
+	}
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"database/sql"
+	"socialnet/model"
+	"socialnet/util"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TrendingRepository handles database operations for engagement events and the cached
+// trending rankings computed from them
+type TrendingRepository struct {
+	db *gorm.DB
+}
+
+// NewTrendingRepository creates a new TrendingRepository
+func NewTrendingRepository(db *gorm.DB) *TrendingRepository {
+	return &TrendingRepository{db}
+}
+
+// recordEngagement records an interaction with a post in the transaction that makes it
+func recordEngagement(tx *gorm.DB, postID, userID uuid.UUID, kind model.EngagementKind) error {
+	return tx.Create(&model.EngagementEvent{PostID: postID, UserID: userID, Kind: kind}).Error
+}
+
+// forgetEngagement removes the latest n of a user's interactions of a kind with a post
+// when they are undone, so that toggling them can't push a post up
+func forgetEngagement(tx *gorm.DB, postID, userID uuid.UUID, kind model.EngagementKind, n int) error {
+	latest := tx.Model(&model.EngagementEvent{}).Select("id").
+		Where("post_id = ? AND user_id = ? AND kind = ?", postID, userID, kind).
+		Order("created_at DESC").Limit(n)
+	return tx.Where("id IN (?)", latest).Delete(&model.EngagementEvent{}).Error
+}
+
+// Refresh recomputes the trending ranking of a window from the events within it. Every
+// event adds its kind's weight divided by its age in hours plus two raised to gravity,
+// so recent activity outweighs older activity within the window.
+func (r *TrendingRepository) Refresh(window string, now time.Time, size int, gravity float64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("time_window = ?", window).Delete(&model.TrendingPost{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			INSERT INTO trending_posts (time_window, post_id, rank, score)
+			SELECT @window, post_id, ROW_NUMBER() OVER (ORDER BY score DESC, post_id), score
+			FROM (
+				SELECT e.post_id, SUM(
+					CASE e.kind WHEN @comment THEN @commentWeight WHEN @share THEN @shareWeight ELSE @reactionWeight END
+					* POWER(EXTRACT(EPOCH FROM (@now - e.created_at)) / 3600 + 2, -@gravity)
+				) AS score
+				FROM engagement_events e
+				JOIN posts ON posts.id = e.post_id AND posts.status = @published AND posts.deleted_at IS NULL
+				WHERE e.created_at > @since
+				GROUP BY e.post_id
+				ORDER BY score DESC
+				LIMIT @size
+			) scored`,
+			sql.Named("window", window),
+			sql.Named("now", now),
+			sql.Named("since", now.Add(-model.TrendingWindows[window])),
+			sql.Named("gravity", gravity),
+			sql.Named("size", size),
+			sql.Named("published", model.PostStatusPublished),
+			sql.Named("comment", model.EngagementComment),
+			sql.Named("share", model.EngagementShare),
+			sql.Named("commentWeight", model.EngagementWeights[model.EngagementComment]),
+			sql.Named("shareWeight", model.EngagementWeights[model.EngagementShare]),
+			sql.Named("reactionWeight", model.EngagementWeights[model.EngagementReaction]),
+		).Error
+	})
+}
+
+// PruneEvents deletes the events that have fallen out of every window
+func (r *TrendingRepository) PruneEvents(before time.Time) (int64, error) {
+	result := r.db.Where("created_at <= ?", before).Delete(&model.EngagementEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// FindTrending finds a page of the trending posts of a window, best ranked first
+func (r *TrendingRepository) FindTrending(window string, filter model.Pagination) ([]model.Post, util.CursorMeta, error) {
+	return findTrending(r.db, window, filter)
+}
+
+// findTrending reads the cached trending ranking of a window
+func findTrending(db *gorm.DB, window string, filter model.Pagination) ([]model.Post, util.CursorMeta, error) {
+	query := db.Scopes(withPostRelations, publishedPosts).
+		Select("posts.*, trending_posts.rank AS trending_rank").
+		Joins("JOIN trending_posts ON trending_posts.post_id = posts.id AND trending_posts.time_window = ?", window)
+
+	key := keyset{sort: "trending-" + window, score: "trending_posts.rank", createdAt: "posts.created_at", id: "posts.id", ascending: true}
+	return findPage(query, key, filter, func(post *model.Post) util.Cursor {
+		return util.Cursor{Score: *post.TrendingRank, CreatedAt: post.CreatedAt, ID: post.ID}
+	})
+}
@@ -0,0 +1,421 @@
+package repository
+
+import (
+	"errors"
+	"socialnet/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUploadNotPending is returned when an upload was completed by another request first
+var ErrUploadNotPending = errors.New("upload is no longer pending")
+
+// unreferenced matches uploads that no entity references
+const unreferenced = "NOT EXISTS (SELECT 1 FROM upload_references WHERE upload_references.upload_id = uploads.id)"
+
+// UploadRepository handles database operations for uploaded files
+type UploadRepository struct {
+	db *gorm.DB
+}
+
+// NewUploadRepository creates a new UploadRepository
+func NewUploadRepository(db *gorm.DB) *UploadRepository {
+	return &UploadRepository{db}
+}
+
+// Create records a new upload
+func (r *UploadRepository) Create(upload *model.Upload) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(upload).Error; err != nil {
+			return err
+		}
+		return recordUploadObjects(tx, upload)
+	})
+}
+
+// FindByID finds an upload by ID
+func (r *UploadRepository) FindByID(id uuid.UUID) (*model.Upload, error) {
+	var upload model.Upload
+	if err := r.db.First(&upload, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// FindByKey finds an upload by the key of its main object or of any of its size variants
+func (r *UploadRepository) FindByKey(key string) (*model.Upload, error) {
+	var upload model.Upload
+	err := r.db.Joins("JOIN upload_objects ON upload_objects.upload_id = uploads.id").
+		First(&upload, "upload_objects.key = ?", key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// recordUploadObjects replaces the object keys recorded for an upload with its current
+// main key and variant keys
+func recordUploadObjects(tx *gorm.DB, upload *model.Upload) error {
+	if err := tx.Where("upload_id = ?", upload.ID).Delete(&model.UploadObject{}).Error; err != nil {
+		return err
+	}
+
+	objects := []model.UploadObject{{Key: upload.Key, UploadID: upload.ID}}
+	for _, key := range upload.Variants {
+		if key != upload.Key {
+			objects = append(objects, model.UploadObject{Key: key, UploadID: upload.ID})
+		}
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&objects).Error
+}
+
+// AppendChunk records a chunk of a resumable upload that starts at offset. It only
+// succeeds while the upload is still expecting that offset, so retried or concurrent
+// requests for the same chunk are counted once. Once every byte has been received the
+// upload is queued for processing.
+func (r *UploadRepository) AppendChunk(upload *model.Upload, offset, length int64) (bool, error) {
+	status := model.UploadStatusUploading
+	if offset+length >= upload.Size {
+		status = model.UploadStatusProcessing
+	}
+
+	result := r.db.Model(&model.Upload{}).
+		Where("id = ? AND status = ? AND received = ?", upload.ID, model.UploadStatusUploading, offset).
+		Updates(map[string]any{
+			"received": offset + length,
+			"status":   status,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	upload.Received = offset + length
+	upload.Status = status
+	return true, nil
+}
+
+// ClaimProcessing locks the oldest upload queued for processing and marks it as being
+// transcoded. Uploads left transcoding since before staleBefore are assumed to belong to
+// a crashed worker and are claimed again. SKIP LOCKED lets several workers poll the
+// queue without picking the same upload. Returns nil when the queue is empty.
+func (r *UploadRepository) ClaimProcessing(staleBefore time.Time) (*model.Upload, error) {
+	var uploads []model.Upload
+	err := r.db.Raw(`
+		UPDATE uploads SET status = ?, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM uploads
+			WHERE status = ? OR (status = ? AND updated_at < ?)
+			ORDER BY created_at ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		model.UploadStatusTranscoding,
+		model.UploadStatusProcessing, model.UploadStatusTranscoding, staleBefore,
+	).Scan(&uploads).Error
+	if err != nil || len(uploads) == 0 {
+		return nil, err
+	}
+	return &uploads[0], nil
+}
+
+// FinishProcessing stores the result of processing an upload and marks every post
+// attachment that points at it as ready
+func (r *UploadRepository) FinishProcessing(upload *model.Upload, mediaURL string, posterURL *string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := resizeUpload(tx, upload); err != nil {
+			return err
+		}
+
+		upload.Status = model.UploadStatusReady
+		if err := tx.Model(upload).
+			Select("status", "size", "variants", "width", "height", "duration").
+			Updates(upload).Error; err != nil {
+			return err
+		}
+
+		if err := recordUploadObjects(tx, upload); err != nil {
+			return err
+		}
+
+		return tx.Model(&model.PostMedia{}).
+			Where("url = ? AND status = ?", mediaURL, model.MediaStatusProcessing).
+			Updates(map[string]any{
+				"status":     model.MediaStatusReady,
+				"poster_url": posterURL,
+				"width":      upload.Width,
+				"height":     upload.Height,
+				"duration":   upload.Duration,
+			}).Error
+	})
+}
+
+// FailProcessing records why an upload could not be processed and marks every post
+// attachment that points at it as failed
+func (r *UploadRepository) FailProcessing(upload *model.Upload, mediaURL, reason string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(upload).Updates(map[string]any{
+			"status": model.UploadStatusFailed,
+			"error":  reason,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&model.PostMedia{}).
+			Where("url = ? AND status = ?", mediaURL, model.MediaStatusProcessing).
+			Update("status", model.MediaStatusFailed).Error
+	})
+}
+
+// StorageUsed returns the bytes reserved and the number of uploads a user owns,
+// including uploads that are still in progress
+func (r *UploadRepository) StorageUsed(userID uuid.UUID) (int64, int64, error) {
+	var usage model.UploadStorageUsage
+	if err := r.db.Where("user_id = ?", userID).Limit(1).Find(&usage).Error; err != nil {
+		return 0, 0, err
+	}
+
+	var count int64
+	err := r.db.Model(&model.Upload{}).Where("user_id = ?", userID).Count(&count).Error
+	return usage.Bytes, count, err
+}
+
+// ReserveStorage counts size bytes against a user's storage quota and reports whether
+// they fit. The check and increment happen in one statement so concurrent uploads cannot
+// exceed the quota. A quota of zero is not enforced.
+func (r *UploadRepository) ReserveStorage(userID uuid.UUID, size, quota int64) (bool, error) {
+	// The first reservation of a user is inserted without checking the condition below
+	if quota > 0 && size > quota {
+		return false, nil
+	}
+
+	result := r.db.Exec(`
+		INSERT INTO upload_storage_usage (user_id, bytes) VALUES (?, ?)
+		ON CONFLICT (user_id) DO UPDATE
+		SET bytes = upload_storage_usage.bytes + EXCLUDED.bytes
+		WHERE (? = 0 OR upload_storage_usage.bytes + EXCLUDED.bytes <= ?)`,
+		userID, size, quota, quota,
+	)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseStorage gives back bytes reserved for an upload that was never recorded
+func (r *UploadRepository) ReleaseStorage(userID uuid.UUID, size int64) error {
+	return adjustStorage(r.db, userID, -size)
+}
+
+// adjustStorage moves a user's storage usage by delta bytes without checking the quota
+func adjustStorage(db *gorm.DB, userID uuid.UUID, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	return db.Exec(`
+		INSERT INTO upload_storage_usage (user_id, bytes) VALUES (?, GREATEST(?::bigint, 0))
+		ON CONFLICT (user_id) DO UPDATE SET bytes = GREATEST(upload_storage_usage.bytes + ?, 0)`,
+		userID, delta, delta,
+	).Error
+}
+
+// resizeUpload moves the owner's storage usage by the difference between an upload's new
+// size and the size it was recorded with. Processing may change the size of an upload
+// after its reservation, so this is not checked against the quota.
+func resizeUpload(tx *gorm.DB, upload *model.Upload) error {
+	var recorded []int64
+	if err := tx.Model(&model.Upload{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", upload.ID).Pluck("size", &recorded).Error; err != nil || len(recorded) == 0 {
+		return err
+	}
+	return adjustStorage(tx, upload.UserID, upload.Size-recorded[0])
+}
+
+// deleteUploads deletes the upload records matching the conditions and gives their size
+// back to their owners' storage usage. Returns the deleted uploads.
+func deleteUploads(db *gorm.DB, query any, args ...any) ([]model.Upload, error) {
+	var deleted []model.Upload
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Returning{}).Where(query, args...).Delete(&deleted).Error; err != nil {
+			return err
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(deleted))
+		for i, upload := range deleted {
+			ids[i] = upload.ID
+		}
+		if err := tx.Where("upload_id IN ?", ids).Delete(&model.UploadObject{}).Error; err != nil {
+			return err
+		}
+
+		for _, upload := range deleted {
+			if err := adjustStorage(tx, upload.UserID, -upload.Size); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// ReleaseDaily gives back an upload counted against a user's daily limits on the given
+// day, for uploads that could not be stored
+func (r *UploadRepository) ReleaseDaily(userID uuid.UUID, day time.Time, size int64) error {
+	return r.db.Model(&model.UploadDailyUsage{}).
+		Where("user_id = ? AND day = ?", userID, day).
+		Updates(map[string]any{
+			"count": gorm.Expr("GREATEST(count - 1, 0)"),
+			"bytes": gorm.Expr("GREATEST(bytes - ?, 0)", size),
+		}).Error
+}
+
+// DailyUsage returns the uploads a user started on the given day
+func (r *UploadRepository) DailyUsage(userID uuid.UUID, day time.Time) (*model.UploadDailyUsage, error) {
+	usage := model.UploadDailyUsage{UserID: userID, Day: day}
+	err := r.db.Where("user_id = ? AND day = ?", userID, day).Limit(1).Find(&usage).Error
+	return &usage, err
+}
+
+// ReserveDaily counts an upload of size bytes against a user's daily limits and reports
+// whether it fits. The check and increment happen in one statement so concurrent uploads
+// cannot exceed the limits. Limits of zero are not enforced.
+func (r *UploadRepository) ReserveDaily(userID uuid.UUID, day time.Time, size int64, maxCount int, maxBytes int64) (bool, error) {
+	// The first upload of a day is inserted without checking the conditions below
+	if maxBytes > 0 && size > maxBytes {
+		return false, nil
+	}
+
+	result := r.db.Exec(`
+		INSERT INTO upload_daily_usage (user_id, day, count, bytes) VALUES (?, ?, 1, ?)
+		ON CONFLICT (user_id, day) DO UPDATE
+		SET count = upload_daily_usage.count + 1, bytes = upload_daily_usage.bytes + EXCLUDED.bytes
+		WHERE (? = 0 OR upload_daily_usage.count < ?)
+		AND (? = 0 OR upload_daily_usage.bytes + EXCLUDED.bytes <= ?)`,
+		userID, day, size, maxCount, maxCount, maxBytes, maxBytes,
+	)
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteUnreferenced deletes an upload owned by the user unless something references it,
+// and reports whether it was deleted
+func (r *UploadRepository) DeleteUnreferenced(id, userID uuid.UUID) (bool, error) {
+	deleted, err := deleteUploads(r.db, "id = ? AND user_id = ? AND "+unreferenced, id, userID)
+	return len(deleted) > 0, err
+}
+
+// MarkReady marks a pending upload as verified and usable and records the processed
+// objects it was published as. Returns ErrUploadNotPending if another request completed
+// the upload first; its objects are then the ones recorded.
+func (r *UploadRepository) MarkReady(upload *model.Upload) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := resizeUpload(tx, upload); err != nil {
+			return err
+		}
+
+		result := tx.Model(&model.Upload{}).
+			Where("id = ? AND status = ?", upload.ID, model.UploadStatusPending).
+			Select("status", "key", "variants", "size", "content_type").
+			Updates(&model.Upload{
+				Status:      model.UploadStatusReady,
+				Key:         upload.Key,
+				Variants:    upload.Variants,
+				Size:        upload.Size,
+				ContentType: upload.ContentType,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUploadNotPending
+		}
+
+		upload.Status = model.UploadStatusReady
+		return recordUploadObjects(tx, upload)
+	})
+}
+
+// Delete removes an upload record
+func (r *UploadRepository) Delete(id uuid.UUID) error {
+	_, err := deleteUploads(r.db, "id = ?", id)
+	return err
+}
+
+// referenceUploads records an entity as a user of the given uploads in the transaction
+// that saves it. With replace, the uploads it no longer uses are released. Only uploads
+// that are ready or still being processed are referenced. Referencing touches the
+// uploads, which locks them against the garbage collector until the transaction ends.
+func referenceUploads(tx *gorm.DB, refType model.UploadRefType, refID uuid.UUID, uploadIDs []uuid.UUID, replace bool) error {
+	if replace {
+		kept := uploadIDs
+		if len(kept) == 0 {
+			kept = []uuid.UUID{uuid.Nil}
+		}
+		if err := releaseUploads(tx, refType, []uuid.UUID{refID}, "upload_id NOT IN ?", kept); err != nil {
+			return err
+		}
+	}
+
+	if len(uploadIDs) == 0 {
+		return nil
+	}
+
+	var usable []model.Upload
+	if err := tx.Model(&usable).Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("id IN ? AND status IN ?", uploadIDs, []model.UploadStatus{
+			model.UploadStatusReady, model.UploadStatusProcessing, model.UploadStatusTranscoding,
+		}).
+		Update("updated_at", gorm.Expr("NOW()")).Error; err != nil {
+		return err
+	}
+	if len(usable) == 0 {
+		return nil
+	}
+
+	refs := make([]model.UploadReference, len(usable))
+	for i, upload := range usable {
+		refs[i] = model.UploadReference{UploadID: upload.ID, RefType: refType, RefID: refID}
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&refs).Error
+}
+
+// releaseUploads drops the upload references of the given entities that match the
+// optional condition. Released uploads are touched, so that the garbage collector gives
+// them the full grace period before deleting them.
+func releaseUploads(tx *gorm.DB, refType model.UploadRefType, refIDs []uuid.UUID, conds ...any) error {
+	var released []model.UploadReference
+	query := tx.Clauses(clause.Returning{Columns: []clause.Column{{Name: "upload_id"}}}).
+		Where("ref_type = ? AND ref_id IN ?", refType, refIDs)
+	if len(conds) > 0 {
+		query = query.Where(conds[0], conds[1:]...)
+	}
+	if err := query.Delete(&released).Error; err != nil || len(released) == 0 {
+		return err
+	}
+
+	uploadIDs := make([]uuid.UUID, len(released))
+	for i, ref := range released {
+		uploadIDs[i] = ref.UploadID
+	}
+	return tx.Model(&model.Upload{}).Where("id IN ?", uploadIDs).Update("updated_at", gorm.Expr("NOW()")).Error
+}
+
+// FindUnreferenced finds uploads that have not been referenced by anything since before
+// the given time
+func (r *UploadRepository) FindUnreferenced(before time.Time, limit int) ([]model.Upload, error) {
+	var uploads []model.Upload
+	err := r.db.Where(unreferenced+" AND updated_at < ?", before).
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&uploads).Error
+	return uploads, err
+}
+
+// DeleteIfUnreferenced deletes an upload record unless it was referenced or touched
+// after the given time, and reports whether it was deleted
+func (r *UploadRepository) DeleteIfUnreferenced(id uuid.UUID, before time.Time) (bool, error) {
+	deleted, err := deleteUploads(r.db, "id = ? AND "+unreferenced+" AND updated_at < ?", id, before)
+	return len(deleted) > 0, err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"socialnet/model"
+	"socialnet/util"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -52,9 +53,21 @@ func (r *UserRepo) FindByUsername(username string) (*model.User, error) {
 	return &user, nil
 }
 
-// Update updates a user in the database
+// Update updates a user in the database and moves the upload references of a changed
+// avatar or cover
 func (r *UserRepo) Update(user *model.User) error {
-	return r.db.Save(user).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+
+		for refType, uploadIDs := range user.ProfileUploads {
+			if err := referenceUploads(tx, refType, user.ID, uploadIDs, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // FindAll finds all users with pagination and search
@@ -145,26 +158,40 @@ func (r *UserRepo) IsFollowing(followerID, followingID uuid.UUID) (bool, error)
 	return count > 0, err
 }
 
-// GetFollowers returns users who follow the specified user
-func (r *UserRepo) GetFollowers(userID uuid.UUID, filter model.FollowFilter) ([]model.User, error) {
-	var users []model.User
-	err := r.db.Table("users").
-		Joins("JOIN follows ON users.id = follows.follower_id").
-		Where("follows.following_id = ?", userID).
-		Limit(filter.Limit).Offset(filter.Offset).
-		Find(&users).Error
-	return users, err
+// GetFollowers returns users who follow the specified user, most recent first
+func (r *UserRepo) GetFollowers(userID uuid.UUID, filter model.FollowFilter) ([]model.User, util.CursorMeta, error) {
+	query := r.db.Preload("Follower").Where("follows.following_id = ?", userID)
+
+	follows, meta, err := findPage(query, newestFollowers, filter.Pagination, func(follow *model.Follow) util.Cursor {
+		return util.Cursor{CreatedAt: follow.CreatedAt, ID: follow.FollowerID}
+	})
+	if err != nil {
+		return nil, meta, err
+	}
+
+	users := make([]model.User, len(follows))
+	for i := range follows {
+		users[i] = follows[i].Follower
+	}
+	return users, meta, nil
 }
 
-// GetFollowing returns users that the specified user follows
-func (r *UserRepo) GetFollowing(userID uuid.UUID, filter model.FollowFilter) ([]model.User, error) {
-	var users []model.User
-	err := r.db.Table("users").
-		Joins("JOIN follows ON users.id = follows.following_id").
-		Where("follows.follower_id = ?", userID).
-		Limit(filter.Limit).Offset(filter.Offset).
-		Find(&users).Error
-	return users, err
+// GetFollowing returns users that the specified user follows, most recent first
+func (r *UserRepo) GetFollowing(userID uuid.UUID, filter model.FollowFilter) ([]model.User, util.CursorMeta, error) {
+	query := r.db.Preload("Following").Where("follows.follower_id = ?", userID)
+
+	follows, meta, err := findPage(query, newestFollowing, filter.Pagination, func(follow *model.Follow) util.Cursor {
+		return util.Cursor{CreatedAt: follow.CreatedAt, ID: follow.FollowingID}
+	})
+	if err != nil {
+		return nil, meta, err
+	}
+
+	users := make([]model.User, len(follows))
+	for i := range follows {
+		users[i] = follows[i].Following
+	}
+	return users, meta, nil
 }
 
 // CountFollowers returns the number of followers for a user
@@ -2,6 +2,9 @@ package router
 
 import (
 	"net/http"
+	"path/filepath"
+	"socialnet/scanner"
+	"socialnet/storage"
 	"socialnet/websocket"
 	"time"
 
@@ -16,7 +19,7 @@ import (
 )
 
 // SetupRouter configures the Gin router
-func SetupRouter(db *gorm.DB, cfg *config.Config, hub *websocket.Hub) *gin.Engine {
+func SetupRouter(db *gorm.DB, cfg *config.Config, hub *websocket.Hub, store storage.Storage, fileScanner scanner.Scanner) *gin.Engine {
 	// Set Gin mode
 	if cfg.Server.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -28,8 +31,8 @@ func SetupRouter(db *gorm.DB, cfg *config.Config, hub *websocket.Hub) *gin.Engin
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.Server.CorsOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization", "Upload-Offset"},
+		ExposeHeaders:    []string{"Content-Length", "Upload-Offset"},
 		AllowCredentials: true,
 		MaxAge:           86400 * time.Second,
 	}))
@@ -38,14 +41,15 @@ func SetupRouter(db *gorm.DB, cfg *config.Config, hub *websocket.Hub) *gin.Engin
 	repo := repository.NewRepository(db)
 
 	// Initialize controllers
-	userController := controller.NewUserController(repo, cfg)
+	userController := controller.NewUserController(repo, store, cfg)
 	authController := controller.NewAuthController(repo, cfg)
-	fileController := controller.NewFileController(cfg)
+	fileController := controller.NewFileController(repo, store, fileScanner, cfg)
 
 	// Initialize post controllers
-	postController := controller.NewPostController(repo, cfg)
+	postController := controller.NewPostController(repo, store, cfg)
 	postInteractionController := controller.NewPostInteractionController(repo, cfg)
 	commentController := controller.NewCommentController(repo, cfg)
+	bookmarkController := controller.NewBookmarkController(repo, cfg)
 
 	// Initialize search controller
 	searchController := controller.NewSearchController(repo, cfg)
@@ -63,6 +67,15 @@ func SetupRouter(db *gorm.DB, cfg *config.Config, hub *websocket.Hub) *gin.Engin
 		})
 	})
 
+	// Serve files from the local storage backend; public objects statically and
+	// everything else only through signed URLs
+	if local, ok := store.(*storage.LocalStorage); ok {
+		r.Static("/files/public", filepath.Join(local.Dir(), storage.LocalPublicPrefix))
+		signed := gin.WrapH(http.StripPrefix("/files/signed", local))
+		r.GET("/files/signed/*key", signed)
+		r.PUT("/files/signed/*key", signed)
+	}
+
 	// API v1 routes
 	v1 := r.Group("/api/v1")
 	{
@@ -90,6 +103,14 @@ func SetupRouter(db *gorm.DB, cfg *config.Config, hub *websocket.Hub) *gin.Engin
 			users.GET("/suggested", userController.GetSuggestedUsers)
 			users.PUT("/:id", userController.UpdateUser)
 			users.GET("/me", userController.GetCurrentUser)
+			users.GET("/me/storage", userController.GetStorageUsage)
+			users.PUT("/me/avatar", userController.UpdateAvatar)
+			users.PUT("/me/cover", userController.UpdateCover)
+			users.GET("/me/bookmarks", bookmarkController.GetBookmarks)
+			users.GET("/me/bookmarks/collections", bookmarkController.GetCollections)
+			users.POST("/me/bookmarks/collections", bookmarkController.CreateCollection)
+			users.PUT("/me/bookmarks/collections/:id", bookmarkController.UpdateCollection)
+			users.DELETE("/me/bookmarks/collections/:id", bookmarkController.DeleteCollection)
 			users.POST("/fcm-token", userController.SaveFCMToken)
 			users.POST("/follow/:id", userController.FollowUser)
 			users.DELETE("/follow/:id", userController.UnfollowUser)
@@ -101,6 +122,12 @@ func SetupRouter(db *gorm.DB, cfg *config.Config, hub *websocket.Hub) *gin.Engin
 		uploads := v1.Group("/uploads", middleware.AuthMiddleware(cfg))
 		{
 			uploads.POST("", fileController.UploadFile)
+			uploads.POST("/presign", fileController.PresignUpload)
+			uploads.POST("/:id/complete", fileController.CompleteUpload)
+			uploads.DELETE("/:id", fileController.DeleteUpload)
+			uploads.POST("/videos", fileController.StartVideoUpload)
+			uploads.GET("/videos/:id", fileController.GetVideoUpload)
+			uploads.PATCH("/videos/:id", fileController.UploadVideoChunk)
 		}
 
 		// Post routes
@@ -112,17 +139,44 @@ func SetupRouter(db *gorm.DB, cfg *config.Config, hub *websocket.Hub) *gin.Engin
 			posts.POST("", postController.CreatePost)
 			posts.PUT("/:id", postController.UpdatePost)
 			posts.DELETE("/:id", postController.DeletePost)
+			posts.GET("/:id/revisions", postController.GetPostRevisions)
+			posts.GET("/:id/thread", postController.GetThread)
+			posts.GET("/drafts", postController.GetDrafts)
+			posts.POST("/drafts", postController.CreateDraft)
+			posts.PUT("/drafts/:id", postController.UpdateDraft)
+			posts.DELETE("/drafts/:id", postController.DeleteDraft)
+			posts.POST("/drafts/:id/publish", postController.PublishDraft)
 			posts.POST("/:id/like", postInteractionController.LikePost)
 			posts.DELETE("/:id/like", postInteractionController.UnlikePost)
+			posts.PUT("/:id/reaction", postInteractionController.ReactToPost)
+			posts.DELETE("/:id/reaction", postInteractionController.RemoveReaction)
+			posts.GET("/:id/reactions", postInteractionController.GetReactions)
+			posts.POST("/:id/bookmark", bookmarkController.BookmarkPost)
+			posts.DELETE("/:id/bookmark", bookmarkController.UnbookmarkPost)
 			posts.POST("/:id/share", postInteractionController.SharePost)
+			posts.DELETE("/:id/share", postInteractionController.UnsharePost)
+			posts.GET("/:id/reposts", postInteractionController.GetReposters)
+			posts.GET("/:id/quotes", postInteractionController.GetQuotes)
+			posts.GET("/:id/poll", postInteractionController.GetPoll)
+			posts.POST("/:id/poll/votes", postInteractionController.VotePoll)
 			posts.GET("/feed", postController.GetFeed)
+			posts.GET("/feed/new-count", postController.GetNewPostCount)
 			posts.GET("/suggested", postController.GetSuggestedPosts)
 
 			// Comment routes (nested under posts)
 			posts.GET("/:id/comments", commentController.GetComments)
 			posts.POST("/:id/comments", commentController.CreateComment)
 			posts.PUT("/comments/:commentId", commentController.UpdateComment)
+			posts.GET("/comments/:commentId/revisions", commentController.GetCommentRevisions)
+			posts.GET("/comments/:commentId/replies", commentController.GetReplies)
+			posts.POST("/comments/:commentId/like", commentController.LikeComment)
+			posts.DELETE("/comments/:commentId/like", commentController.UnlikeComment)
 			posts.DELETE("/comments/:commentId", commentController.DeleteComment)
+			posts.POST("/comments/:commentId/hide", commentController.HideComment)
+			posts.DELETE("/comments/:commentId/hide", commentController.UnhideComment)
+			posts.PUT("/:id/comment-policy", commentController.UpdateCommentPolicy)
+			posts.PUT("/:id/pinned-comment", commentController.PinComment)
+			posts.DELETE("/:id/pinned-comment", commentController.UnpinComment)
 		}
 
 		// Search routes
@@ -0,0 +1,107 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of the chunks streamed to clamd; it must stay below the
+// daemon's StreamMaxLength
+const clamdChunkSize = 64 * 1024
+
+// Clamd scans files with a ClamAV daemon using the INSTREAM command
+type Clamd struct {
+	address string
+	timeout time.Duration
+}
+
+// NewClamd creates a scanner for the clamd listening on address, either host:port or the
+// path of a unix socket
+func NewClamd(address string, timeout time.Duration) *Clamd {
+	return &Clamd{address: address, timeout: timeout}
+}
+
+// Scan streams the file to clamd and parses its verdict
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	network := "tcp"
+	if strings.HasPrefix(c.address, "/") {
+		network = "unix"
+	}
+
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to start clamd scan: %w", err)
+	}
+
+	// Each chunk is prefixed with its length; a zero length chunk ends the stream
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return nil, fmt.Errorf("failed to stream file to clamd: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return nil, fmt.Errorf("failed to stream file to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("failed to finish clamd scan: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+
+	return parseClamdReply(string(bytes.TrimRight(reply, "\x00")))
+}
+
+// parseClamdReply parses replies such as "stream: OK" and
+// "stream: Eicar-Signature FOUND"
+func parseClamdReply(reply string) (*Result, error) {
+	_, verdict, found := strings.Cut(reply, ": ")
+	if !found {
+		return nil, fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+
+	switch {
+	case verdict == "OK":
+		return &Result{Clean: true}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &Result{Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd scan failed: %s", verdict)
+	}
+}
@@ -0,0 +1,32 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// eicarSignature is the standard anti-virus test string
+var eicarSignature = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+
+// Fake is a scanner for development and tests that reports files containing the EICAR
+// test string as infected and everything else as clean
+type Fake struct{}
+
+// NewFake creates a fake scanner
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+// Scan reads the file and looks for the EICAR test string
+func (f *Fake) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Contains(data, eicarSignature) {
+		return &Result{Signature: "Eicar-Test-Signature"}, nil
+	}
+	return &Result{Clean: true}, nil
+}
@@ -0,0 +1,38 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"socialnet/config"
+)
+
+// Result is the verdict of a scan
+type Result struct {
+	Clean bool
+	// Signature names the threat found in an infected file
+	Signature string
+}
+
+// Scanner checks uploaded files for malware
+type Scanner interface {
+	// Scan reads the whole file and reports whether it is clean. An error means the file
+	// could not be scanned and must not be trusted.
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+// New creates the scanner selected in the configuration. It returns nil when scanning is
+// disabled.
+func New(cfg *config.Config) (Scanner, error) {
+	switch cfg.Scanner.Driver {
+	case "", "none":
+		return nil, nil
+	case "clamav":
+		return NewClamd(cfg.Scanner.ClamdAddress, cfg.Scanner.Timeout), nil
+	case "fake":
+		return NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown scanner driver %q", cfg.Scanner.Driver)
+	}
+}
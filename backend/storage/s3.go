@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	"socialnet/config"
+)
+
+// S3Storage stores objects in an S3 compatible bucket
+type S3Storage struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+	baseURL string
+}
+
+// NewS3Storage creates an S3 backed storage from the AWS configuration
+func NewS3Storage(cfg *config.Config) (*S3Storage, error) {
+	var loadOptions []func(*awsconfig.LoadOptions) error
+	if cfg.AWS.AccessKeyID != "" {
+		loadOptions = append(loadOptions, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey, "")))
+	}
+
+	if cfg.AWS.Region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(cfg.AWS.Region))
+	}
+
+	if cfg.AWS.Endpoint != "" {
+		loadOptions = append(loadOptions, awsconfig.WithBaseEndpoint(cfg.AWS.Endpoint))
+	}
+
+	c, err := awsconfig.LoadDefaultConfig(context.Background(), loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	baseURL := cfg.AWS.CdnURL
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", cfg.AWS.Bucket, cfg.AWS.Region)
+	}
+
+	client := s3.NewFromConfig(c)
+	return &S3Storage{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		bucket:  cfg.AWS.Bucket,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}, nil
+}
+
+// Put uploads an object to the bucket
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        &s.bucket,
+		Key:           &key,
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		ContentType:   &contentType,
+	})
+	return err
+}
+
+// Delete removes an object from the bucket
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	return err
+}
+
+// Exists checks whether an object is present in the bucket
+func (s *S3Storage) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err == nil {
+		return true, nil
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// Stat returns the size and content type of an object
+func (s *S3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectInfo{
+		Size:        aws.ToInt64(out.ContentLength),
+		ContentType: aws.ToString(out.ContentType),
+	}, nil
+}
+
+// GetRange reads part of an object using an HTTP range request
+func (s *S3Storage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// SignedURL returns a presigned GET URL for an object
+func (s *S3Storage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	req, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// PresignPut returns a presigned PUT URL; S3 rejects uploads whose content type or
+// length differ from the signed values
+func (s *S3Storage) PresignPut(ctx context.Context, key, contentType string, size int64, expiry time.Duration) (string, error) {
+	req, err := s.presign.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        &s.bucket,
+		Key:           &key,
+		ContentType:   &contentType,
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// URL returns the public (CDN or bucket) URL of an object
+func (s *S3Storage) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// isNotFound reports whether an S3 error means the object does not exist
+func isNotFound(err error) bool {
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return true
+	}
+
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+
+	var respErr *smithyhttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound
+}
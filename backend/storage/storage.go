@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"socialnet/config"
+)
+
+var (
+	// ErrInvalidKey is returned for object keys that would escape the storage root
+	ErrInvalidKey = errors.New("invalid object key")
+	// ErrNotFound is returned when an object does not exist
+	ErrNotFound = errors.New("object not found")
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+// Storage is a backend that uploaded objects are written to and served from
+type Storage interface {
+	// Put stores an object under the given key, replacing any existing object
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Delete removes an object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// Exists reports whether an object is stored under the given key
+	Exists(ctx context.Context, key string) (bool, error)
+	// Stat returns the size and content type of an object, or ErrNotFound
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// GetRange reads length bytes of an object starting at offset
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// SignedURL returns a time-limited URL granting read access to a private object
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// PresignPut returns a time-limited URL that accepts a single PUT of an object with
+	// the given content type and size
+	PresignPut(ctx context.Context, key, contentType string, size int64, expiry time.Duration) (string, error)
+	// URL returns the public URL of an object
+	URL(key string) string
+}
+
+// New creates the storage backend selected in the configuration
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.Storage.Driver {
+	case "", "s3":
+		return NewS3Storage(cfg)
+	case "local":
+		return NewLocalStorage(cfg.Storage.LocalDir, cfg.Storage.LocalBaseURL, cfg.JWT.Secret)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
+
+// KeyFromURL returns the object key of a public URL produced by the storage backend
+func KeyFromURL(s Storage, fileURL string) (string, bool) {
+	prefix := s.URL("")
+	if !strings.HasPrefix(fileURL, prefix) {
+		return "", false
+	}
+
+	key := strings.TrimPrefix(fileURL, prefix)
+	if cleanKey(key) != key || strings.ContainsAny(key, "?#") {
+		return "", false
+	}
+
+	return key, true
+}
+
+// cleanKey normalises an object key, returning an empty string for keys that are
+// absolute or would escape the storage root
+func cleanKey(key string) string {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ""
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return ""
+	}
+
+	return cleaned
+}
@@ -0,0 +1,162 @@
+package transcoder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxRenditionHeight is the height renditions are scaled down to
+	maxRenditionHeight = 720
+	// maxDuration is the longest video that is transcoded
+	maxDuration = 10 * time.Minute
+)
+
+// FFmpeg transcodes videos by running the ffmpeg and ffprobe binaries
+type FFmpeg struct {
+	ffmpegPath  string
+	ffprobePath string
+}
+
+// NewFFmpeg creates a transcoder that runs the given ffmpeg and ffprobe binaries
+func NewFFmpeg(ffmpegPath, ffprobePath string) *FFmpeg {
+	return &FFmpeg{ffmpegPath: ffmpegPath, ffprobePath: ffprobePath}
+}
+
+// probeOutput is the subset of ffprobe's JSON output that is used
+type probeOutput struct {
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+	} `json:"streams"`
+}
+
+// Transcode produces an H.264/AAC MP4 rendition with metadata stripped and the index
+// moved to the front for progressive playback, plus a poster frame
+func (f *FFmpeg) Transcode(ctx context.Context, input, outputDir string) (*Result, error) {
+	duration, err := f.probe(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		VideoPath:  filepath.Join(outputDir, "video.mp4"),
+		PosterPath: filepath.Join(outputDir, "poster.jpg"),
+		Duration:   duration,
+	}
+
+	scale := fmt.Sprintf("scale=-2:'min(%d,ih)'", maxRenditionHeight)
+
+	if err := f.run(ctx, f.ffmpegPath,
+		"-v", "error", "-y",
+		"-i", input,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-map_metadata", "-1",
+		"-vf", scale,
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", "128k",
+		"-movflags", "+faststart",
+		result.VideoPath,
+	); err != nil {
+		return nil, err
+	}
+
+	// Take the poster from the rendition so that it matches its size and orientation
+	posterAt := min(time.Second, duration/2)
+	if err := f.run(ctx, f.ffmpegPath,
+		"-v", "error", "-y",
+		"-ss", strconv.FormatFloat(posterAt.Seconds(), 'f', 3, 64),
+		"-i", result.VideoPath,
+		"-frames:v", "1",
+		"-q:v", "3",
+		result.PosterPath,
+	); err != nil {
+		return nil, err
+	}
+
+	// Probe the rendition for its final dimensions
+	out, err := f.probeStreams(ctx, result.VideoPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, stream := range out.Streams {
+		if stream.CodecType == "video" {
+			result.Width = stream.Width
+			result.Height = stream.Height
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// probe checks that the input contains a video stream and returns its duration
+func (f *FFmpeg) probe(ctx context.Context, input string) (time.Duration, error) {
+	out, err := f.probeStreams(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+
+	hasVideo := false
+	for _, stream := range out.Streams {
+		if stream.CodecType == "video" {
+			hasVideo = true
+			break
+		}
+	}
+
+	seconds, err := strconv.ParseFloat(out.Format.Duration, 64)
+	if !hasVideo || err != nil || seconds <= 0 {
+		return 0, ErrUnreadableVideo
+	}
+
+	duration := time.Duration(seconds * float64(time.Second))
+	if duration > maxDuration {
+		return 0, ErrVideoTooLong
+	}
+
+	return duration, nil
+}
+
+// probeStreams runs ffprobe on a file
+func (f *FFmpeg) probeStreams(ctx context.Context, input string) (*probeOutput, error) {
+	cmd := exec.CommandContext(ctx, f.ffprobePath,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format", "-show_streams",
+		input,
+	)
+
+	stdout, err := cmd.Output()
+	if err != nil {
+		return nil, ErrUnreadableVideo
+	}
+
+	var out probeOutput
+	if err := json.Unmarshal(stdout, &out); err != nil {
+		return nil, ErrUnreadableVideo
+	}
+	return &out, nil
+}
+
+// run executes a command, including its error output in the returned error
+func (f *FFmpeg) run(ctx context.Context, name string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", filepath.Base(name), err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package transcoder
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"socialnet/config"
+)
+
+var (
+	// ErrUnreadableVideo is returned when the input cannot be decoded as a video
+	ErrUnreadableVideo = errors.New("video could not be read")
+	// ErrVideoTooLong is returned when the input exceeds the maximum duration
+	ErrVideoTooLong = errors.New("video is too long")
+)
+
+// Result describes the files produced from a source video
+type Result struct {
+	// VideoPath is a web friendly rendition of the source
+	VideoPath string
+	// PosterPath is a JPEG frame shown before playback starts
+	PosterPath string
+	Duration   time.Duration
+	Width      int
+	Height     int
+}
+
+// Transcoder converts uploaded videos into a rendition that plays in every browser
+type Transcoder interface {
+	// Transcode reads the video at input and writes its rendition and poster frame into
+	// outputDir
+	Transcode(ctx context.Context, input, outputDir string) (*Result, error)
+}
+
+// New creates the transcoder selected in the configuration
+func New(cfg *config.Config) Transcoder {
+	return NewFFmpeg(cfg.Video.FFmpegPath, cfg.Video.FFprobePath)
+}
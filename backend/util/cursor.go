@@ -0,0 +1,50 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned for cursors that weren't issued for the requested list
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the position of a row in a keyset-paginated list. Clients get it as an
+// opaque string and send it back to fetch the rows after it, or before it when Before
+// is set.
+type Cursor struct {
+	Sort      string    `json:"o,omitempty"`
+	Before    bool      `json:"b,omitempty"`
+	Score     int       `json:"s,omitempty"`
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// Encode returns the opaque form of the cursor
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses an opaque cursor issued for a list in the given sort order
+func DecodeCursor(value, sort string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil || cursor.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// CursorMeta is the pagination metadata of a cursor-paginated response
+type CursorMeta struct {
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
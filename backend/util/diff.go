@@ -0,0 +1,104 @@
+package util
+
+import "strings"
+
+// DiffOpType is the kind of change a diff segment describes
+type DiffOpType string
+
+const (
+	DiffEqual  DiffOpType = "equal"
+	DiffInsert DiffOpType = "insert"
+	DiffDelete DiffOpType = "delete"
+)
+
+// DiffOp is a run of text that was kept, inserted or deleted
+type DiffOp struct {
+	Type DiffOpType `json:"type"`
+	Text string     `json:"text"`
+}
+
+// maxDiffCells bounds the size of the LCS table; longer texts are diffed as a whole
+// replacement
+const maxDiffCells = 1_000_000
+
+// WordDiff returns the word level changes that turn oldText into newText. Whitespace is
+// kept attached to the following word so that joining the texts of the equal and delete
+// segments gives oldText back, and equal and insert segments give newText.
+func WordDiff(oldText, newText string) []DiffOp {
+	a, b := splitWords(oldText), splitWords(newText)
+
+	if len(a)*len(b) > maxDiffCells {
+		return appendDiffOp(appendDiffOp(nil, DiffDelete, oldText), DiffInsert, newText)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []DiffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = appendDiffOp(ops, DiffEqual, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = appendDiffOp(ops, DiffDelete, a[i])
+			i++
+		default:
+			ops = appendDiffOp(ops, DiffInsert, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = appendDiffOp(ops, DiffDelete, a[i])
+	}
+	for ; j < len(b); j++ {
+		ops = appendDiffOp(ops, DiffInsert, b[j])
+	}
+
+	return ops
+}
+
+// appendDiffOp appends text to the last segment when it has the same type
+func appendDiffOp(ops []DiffOp, opType DiffOpType, text string) []DiffOp {
+	if text == "" {
+		return ops
+	}
+	if n := len(ops); n > 0 && ops[n-1].Type == opType {
+		ops[n-1].Text += text
+		return ops
+	}
+	return append(ops, DiffOp{Type: opType, Text: text})
+}
+
+// splitWords splits text into words, each carrying the whitespace that precedes it
+func splitWords(text string) []string {
+	var words []string
+	start := 0
+	inSpace := true
+	for i, r := range text {
+		isSpace := strings.ContainsRune(" \t\n\r", r)
+		if isSpace && !inSpace && i > start {
+			words = append(words, text[start:i])
+			start = i
+		}
+		inSpace = isSpace
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
@@ -0,0 +1,84 @@
+package util
+
+import "unicode/utf8"
+
+// maxEmojiBytes bounds custom reactions; the longest standard ZWJ sequences fit easily
+const maxEmojiBytes = 32
+
+// IsEmoji reports whether s is a single emoji, including skin tone, flag, keycap and
+// zero width joiner sequences. Runs of several emoji are rejected: pictographs joined by
+// a zero width joiner, a pair of regional indicators and a keycap sequence each count as
+// one, and exactly one is allowed.
+func IsEmoji(s string) bool {
+	if s == "" || len(s) > maxEmojiBytes || !utf8.ValidString(s) {
+		return false
+	}
+
+	runes := []rune(s)
+	emoji := 0
+	joined := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case isRegionalIndicator(r):
+			// A flag is a pair of regional indicators
+			if i+1 >= len(runes) || !isRegionalIndicator(runes[i+1]) {
+				return false
+			}
+			i++
+			emoji++
+		case r == '#' || r == '*' || (r >= '0' && r <= '9'):
+			// A combining keycap, optionally after a variation selector, turns a digit into an emoji
+			if i+1 < len(runes) && runes[i+1] == 0xFE0F {
+				i++
+			}
+			if i+1 >= len(runes) || runes[i+1] != 0x20E3 {
+				return false
+			}
+			i++
+			emoji++
+		case r == 0x200D: // zero width joiner
+			if emoji == 0 || joined {
+				return false
+			}
+			joined = true
+			continue
+		case r >= 0xFE00 && r <= 0xFE0F, // variation selectors
+			r >= 0x1F3FB && r <= 0x1F3FF, // skin tones
+			r >= 0xE0020 && r <= 0xE007F: // tag sequences
+			if emoji == 0 || joined {
+				return false
+			}
+		case isPictograph(r):
+			if !joined {
+				emoji++
+			}
+		default:
+			return false
+		}
+		joined = false
+	}
+	return emoji == 1 && !joined
+}
+
+// isRegionalIndicator reports whether r is one of the letters that pair up into flags
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// isPictograph reports whether r is in one of the emoji blocks
+func isPictograph(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, // symbols, pictographs, emoticons, transport, flags
+		r >= 0x2600 && r <= 0x27BF, // miscellaneous symbols and dingbats
+		r >= 0x2300 && r <= 0x23FF, // miscellaneous technical
+		r >= 0x2B00 && r <= 0x2BFF, // arrows and stars
+		r == 0x00A9 || r == 0x00AE || r == 0x203C || r == 0x2049 || r == 0x2122 || r == 0x2139,
+		r >= 0x2194 && r <= 0x21AA,
+		r == 0x24C2 || r == 0x25AA || r == 0x25AB || r == 0x25B6 || r == 0x25C0,
+		r >= 0x25FB && r <= 0x25FE,
+		r == 0x3030 || r == 0x303D || r == 0x3297 || r == 0x3299:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package util
+
+import "testing"
+
+func TestIsEmoji(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"single pictograph", "👍", true},
+		{"variation selector", "❤️", true},
+		{"skin tone", "👍🏽", true},
+		{"zero width joiner sequence", "👩‍💻", true},
+		{"family sequence", "👨‍👩‍👧‍👦", true},
+		{"flag", "🇩🇪", true},
+		{"tag sequence", "🏴\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F", true},
+		{"keycap", "1️⃣", true},
+		{"keycap without variation selector", "#⃣", true},
+		{"empty", "", false},
+		{"text", "ok", false},
+		{"bare digit", "1", false},
+		{"two pictographs", "👍👍", false},
+		{"run of pictographs", "😀😃😄😁", false},
+		{"two flags", "🇩🇪🇫🇷", false},
+		{"lone regional indicator", "🇩", false},
+		{"pictograph and flag", "👍🇩🇪", false},
+		{"two keycaps", "1️⃣2️⃣", false},
+		{"trailing joiner", "👩‍", false},
+		{"leading modifier", "🏽👍", false},
+		{"pictograph and text", "👍a", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsEmoji(tt.input); got != tt.want {
+				t.Errorf("IsEmoji(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
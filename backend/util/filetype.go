@@ -0,0 +1,486 @@
+package util
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// MaxImageDimension is the largest accepted width or height of an uploaded image
+	MaxImageDimension = 10000
+	// MaxImagePixels caps the decoded size of an image to guard against decompression bombs
+	MaxImagePixels = 36_000_000
+	// MaxGIFPixels caps the total pixels of all frames of an animated GIF
+	MaxGIFPixels = 100_000_000
+	// maxInflatedText caps how much of a compressed PNG text chunk is inflated for scanning
+	maxInflatedText = 1 << 20
+)
+
+var (
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+	ErrExtensionMismatch   = errors.New("file extension does not match its content")
+	ErrEmbeddedContent     = errors.New("file contains embedded content")
+	ErrImageTooLarge       = errors.New("image dimensions are too large")
+)
+
+// DetectedImage describes an image identified from its content
+type DetectedImage struct {
+	Format      string
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// imageFormat describes an accepted image format
+type imageFormat struct {
+	name        string
+	contentType string
+	ext         string
+	magic       func([]byte) bool
+	// scan walks the structure of the file and returns the segments that can carry
+	// free-form content, such as comments and metadata, and the length of the image data
+	scan func([]byte) ([][]byte, int, error)
+	// trailingData accepts data after the end of the image, which is scanned instead
+	trailingData bool
+}
+
+var imageFormats = []imageFormat{
+	// Cameras commonly append maker data after the JPEG end marker, so it is scanned
+	// rather than rejected
+	{
+		name:         "jpeg",
+		contentType:  "image/jpeg",
+		ext:          ".jpg",
+		magic:        func(b []byte) bool { return bytes.HasPrefix(b, []byte{0xFF, 0xD8, 0xFF}) },
+		scan:         scanJPEG,
+		trailingData: true,
+	},
+	{
+		name:        "png",
+		contentType: "image/png",
+		ext:         ".png",
+		magic:       func(b []byte) bool { return bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")) },
+		scan:        scanPNG,
+	},
+	{
+		name:        "gif",
+		contentType: "image/gif",
+		ext:         ".gif",
+		magic: func(b []byte) bool {
+			return bytes.HasPrefix(b, []byte("GIF87a")) || bytes.HasPrefix(b, []byte("GIF89a"))
+		},
+		scan: func(b []byte) ([][]byte, int, error) {
+			_, segments, length, err := scanGIF(b)
+			return segments, length, err
+		},
+	},
+	{
+		name:        "webp",
+		contentType: "image/webp",
+		ext:         ".webp",
+		magic: func(b []byte) bool {
+			return len(b) >= 12 && bytes.HasPrefix(b, []byte("RIFF")) && bytes.Equal(b[8:12], []byte("WEBP"))
+		},
+		scan: scanWebP,
+	},
+}
+
+// extContentTypes maps accepted file extensions to the content type they must contain
+var extContentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// DetectedVideo describes a video container identified from its content
+type DetectedVideo struct {
+	ContentType string
+	Ext         string
+}
+
+// videoExtContentTypes maps accepted video extensions to the content type they must contain
+var videoExtContentTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mov":  "video/quicktime",
+	".webm": "video/webm",
+}
+
+// videoExts is the canonical extension of each accepted video content type
+var videoExts = map[string]string{
+	"video/mp4":       ".mp4",
+	"video/quicktime": ".mov",
+	"video/webm":      ".webm",
+}
+
+// VideoContentType returns the content type an accepted video extension must contain
+func VideoContentType(ext string) (string, bool) {
+	contentType, ok := videoExtContentTypes[strings.ToLower(ext)]
+	return contentType, ok
+}
+
+// DetectVideo identifies a video container from the first bytes of an upload. Only the
+// container is checked here; whether the streams can be decoded is left to the
+// transcoder, which rejects anything it cannot read.
+func DetectVideo(head []byte, filename string) (*DetectedVideo, error) {
+	var contentType string
+	switch {
+	case len(head) >= 12 && bytes.Equal(head[4:8], []byte("ftyp")):
+		// ISO base media files start with an ftyp box whose major brand tells QuickTime
+		// movies apart from MP4
+		if bytes.Equal(head[8:12], []byte("qt  ")) {
+			contentType = "video/quicktime"
+		} else {
+			contentType = "video/mp4"
+		}
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}) && bytes.Contains(head[:min(len(head), 64)], []byte("webm")):
+		contentType = "video/webm"
+	default:
+		return nil, ErrUnsupportedFileType
+	}
+
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != "" && videoExtContentTypes[ext] != contentType {
+		return nil, ErrExtensionMismatch
+	}
+
+	return &DetectedVideo{ContentType: contentType, Ext: videoExts[contentType]}, nil
+}
+
+// ImageContentType returns the content type an accepted image extension must contain
+func ImageContentType(ext string) (string, bool) {
+	contentType, ok := extContentTypes[strings.ToLower(ext)]
+	return contentType, ok
+}
+
+// embeddedSignatures are markers of content that turns an image into a polyglot that
+// browsers or other tools could interpret as markup, scripts or archives
+var embeddedSignatures = [][]byte{
+	[]byte("<script"),
+	[]byte("<html"),
+	[]byte("<!doctype html"),
+	[]byte("<?php"),
+	[]byte("<svg"),
+	[]byte("<iframe"),
+	[]byte("<body"),
+	[]byte("javascript:"),
+	[]byte("%pdf-"),
+	[]byte("pk\x03\x04"),
+}
+
+// DetectImage identifies an uploaded image from its magic bytes and decoded header,
+// rather than trusting its file name. It rejects files whose extension disagrees with
+// their content, files carrying embedded markup or archives, and images whose decoded
+// size exceeds the pixel limits.
+func DetectImage(data []byte, filename string) (*DetectedImage, error) {
+	var format *imageFormat
+	for i := range imageFormats {
+		if imageFormats[i].magic(data) {
+			format = &imageFormats[i]
+			break
+		}
+	}
+
+	// The standard library sniffer must agree with the magic bytes
+	if format == nil || http.DetectContentType(data) != format.contentType {
+		return nil, ErrUnsupportedFileType
+	}
+
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != "" && extContentTypes[ext] != format.contentType {
+		return nil, ErrExtensionMismatch
+	}
+
+	config, decodedFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decodedFormat != format.name {
+		return nil, ErrUnsupportedFileType
+	}
+
+	if config.Width <= 0 || config.Height <= 0 ||
+		config.Width > MaxImageDimension || config.Height > MaxImageDimension ||
+		config.Width*config.Height > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	if format.name == "gif" {
+		frames, _, _, err := scanGIF(data)
+		if err != nil {
+			return nil, err
+		}
+		if frames*config.Width*config.Height > MaxGIFPixels {
+			return nil, ErrImageTooLarge
+		}
+	}
+
+	if err := checkEmbeddedContent(data, format); err != nil {
+		return nil, err
+	}
+
+	return &DetectedImage{
+		Format:      format.name,
+		ContentType: format.contentType,
+		Ext:         format.ext,
+		Width:       config.Width,
+		Height:      config.Height,
+	}, nil
+}
+
+// checkEmbeddedContent rejects data appended after the end of the image and known
+// markers of other file types in the parts of the file that can hold free-form content.
+// Pixel data is left out, since short signatures turn up in compressed data by chance.
+func checkEmbeddedContent(data []byte, format *imageFormat) error {
+	segments, length, err := format.scan(data)
+	if err != nil {
+		return err
+	}
+
+	if length < len(data) {
+		if !format.trailingData {
+			return ErrEmbeddedContent
+		}
+		segments = append(segments, data[length:])
+	}
+
+	for _, segment := range segments {
+		lower := bytes.ToLower(segment)
+		for _, signature := range embeddedSignatures {
+			if bytes.Contains(lower, signature) {
+				return ErrEmbeddedContent
+			}
+		}
+	}
+
+	return nil
+}
+
+// scanJPEG walks the JPEG markers and returns the application and comment segments and
+// the offset just past the end of image marker
+func scanJPEG(data []byte) ([][]byte, int, error) {
+	var segments [][]byte
+	offset := 2
+	for offset+1 < len(data) {
+		if data[offset] != 0xFF {
+			return nil, 0, ErrUnsupportedFileType
+		}
+		marker := data[offset+1]
+		switch {
+		case marker == 0xFF: // fill byte
+			offset++
+			continue
+		case marker == 0xD9: // end of image
+			return segments, offset + 2, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD8): // markers without a length
+			offset += 2
+			continue
+		}
+
+		if offset+4 > len(data) {
+			return nil, 0, ErrUnsupportedFileType
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		next := offset + 2 + length
+		if length < 2 || next > len(data) {
+			return nil, 0, ErrUnsupportedFileType
+		}
+		if (marker >= 0xE0 && marker <= 0xEF) || marker == 0xFE {
+			segments = append(segments, data[offset+4:next])
+		}
+		offset = next
+
+		// Entropy-coded data follows the start of scan header up to the next marker that
+		// is neither a stuffed 0xFF nor a restart marker
+		if marker == 0xDA {
+			for offset+1 < len(data) && (data[offset] != 0xFF || data[offset+1] == 0x00 || (data[offset+1] >= 0xD0 && data[offset+1] <= 0xD7)) {
+				offset++
+			}
+		}
+	}
+	return nil, 0, ErrUnsupportedFileType
+}
+
+// scanPNG walks the PNG chunks and returns the text and EXIF chunks, with compressed text
+// inflated, and the offset just past the IEND chunk
+func scanPNG(data []byte) ([][]byte, int, error) {
+	var segments [][]byte
+	offset := 8
+	for offset+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		chunkType := string(data[offset+4 : offset+8])
+		next := offset + 12 + length
+		if length < 0 || next > len(data) {
+			return nil, 0, ErrUnsupportedFileType
+		}
+		chunk := data[offset+8 : offset+8+length]
+
+		switch chunkType {
+		case "IEND":
+			return segments, next, nil
+		case "tEXt", "eXIf":
+			segments = append(segments, chunk)
+		case "zTXt":
+			// keyword, null separator, compression method, compressed text
+			keyword, text, _ := bytes.Cut(chunk, []byte{0})
+			if len(text) < 1 {
+				return nil, 0, ErrUnsupportedFileType
+			}
+			inflated, err := inflateText(text[1:])
+			if err != nil {
+				return nil, 0, err
+			}
+			segments = append(segments, keyword, inflated)
+		case "iTXt":
+			// keyword, null, compression flag, compression method, language tag, null,
+			// translated keyword, null, text
+			keyword, rest, _ := bytes.Cut(chunk, []byte{0})
+			if len(rest) < 2 {
+				return nil, 0, ErrUnsupportedFileType
+			}
+			compressed := rest[0] == 1
+			language, rest, _ := bytes.Cut(rest[2:], []byte{0})
+			translated, text, _ := bytes.Cut(rest, []byte{0})
+			if compressed {
+				inflated, err := inflateText(text)
+				if err != nil {
+					return nil, 0, err
+				}
+				text = inflated
+			}
+			segments = append(segments, keyword, language, translated, text)
+		}
+		offset = next
+	}
+	return nil, 0, ErrUnsupportedFileType
+}
+
+// inflateText decompresses the text of a PNG text chunk, up to a limit
+func inflateText(compressed []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, ErrUnsupportedFileType
+	}
+	defer reader.Close()
+
+	text, err := io.ReadAll(io.LimitReader(reader, maxInflatedText))
+	if err != nil {
+		return nil, ErrUnsupportedFileType
+	}
+	return text, nil
+}
+
+// webpImageChunks are the RIFF chunks that hold image data rather than metadata
+var webpImageChunks = map[string]bool{
+	"VP8 ": true, "VP8L": true, "VP8X": true, "ALPH": true, "ANIM": true, "ANMF": true, "ICCP": true,
+}
+
+// scanWebP walks the RIFF chunks of a WebP file and returns the metadata and unknown
+// chunks and the length of the container declared in its header
+func scanWebP(data []byte) ([][]byte, int, error) {
+	size := int(binary.LittleEndian.Uint32(data[4:8]))
+	// RIFF chunks are padded to an even length
+	length := 8 + size + size%2
+	if length > len(data) {
+		return nil, 0, ErrUnsupportedFileType
+	}
+	end := length
+
+	var segments [][]byte
+	offset := 12
+	for offset+8 <= end {
+		chunkType := string(data[offset : offset+4])
+		chunkSize := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		next := offset + 8 + chunkSize + chunkSize%2
+		if chunkSize < 0 || offset+8+chunkSize > end {
+			return nil, 0, ErrUnsupportedFileType
+		}
+		if !webpImageChunks[chunkType] {
+			segments = append(segments, data[offset+8:offset+8+chunkSize])
+		}
+		offset = next
+	}
+	return segments, length, nil
+}
+
+// scanGIF walks the GIF blocks without decoding pixels and returns the number of frames,
+// the comment, plain text and application extensions and the offset just past the
+// trailer
+func scanGIF(data []byte) (int, [][]byte, int, error) {
+	if len(data) < 13 {
+		return 0, nil, 0, ErrUnsupportedFileType
+	}
+
+	offset := 13
+	if packed := data[10]; packed&0x80 != 0 {
+		offset += 3 << ((packed & 0x07) + 1)
+	}
+
+	frames := 0
+	var segments [][]byte
+	for offset < len(data) {
+		switch data[offset] {
+		case 0x21: // extension: introducer, label, sub-blocks
+			if offset+2 > len(data) {
+				return 0, nil, 0, ErrUnsupportedFileType
+			}
+			label := data[offset+1]
+			collect := label == 0xFE || label == 0xFF || label == 0x01
+			content, next, err := gifSubBlocks(data, offset+2, collect)
+			if err != nil {
+				return 0, nil, 0, err
+			}
+			if collect {
+				segments = append(segments, content)
+			}
+			offset = next
+		case 0x2C: // image descriptor, optional local color table, LZW code size, sub-blocks
+			if offset+10 > len(data) {
+				return 0, nil, 0, ErrUnsupportedFileType
+			}
+			packed := data[offset+9]
+			offset += 10
+			if packed&0x80 != 0 {
+				offset += 3 << ((packed & 0x07) + 1)
+			}
+			_, next, err := gifSubBlocks(data, offset+1, false)
+			if err != nil {
+				return 0, nil, 0, err
+			}
+			offset = next
+			frames++
+		case 0x3B: // trailer
+			return frames, segments, offset + 1, nil
+		default:
+			return 0, nil, 0, ErrUnsupportedFileType
+		}
+	}
+
+	return 0, nil, 0, ErrUnsupportedFileType
+}
+
+// gifSubBlocks returns the offset just past a sequence of GIF data sub-blocks and, if
+// collect is set, their joined content
+func gifSubBlocks(data []byte, offset int, collect bool) ([]byte, int, error) {
+	var content []byte
+	for offset < len(data) {
+		size := int(data[offset])
+		offset++
+		if size == 0 {
+			return content, offset, nil
+		}
+		if offset+size > len(data) {
+			return nil, 0, ErrUnsupportedFileType
+		}
+		if collect {
+			content = append(content, data[offset:offset+size]...)
+		}
+		offset += size
+	}
+	return nil, 0, ErrUnsupportedFileType
+}
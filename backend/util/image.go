@@ -0,0 +1,288 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // register the WebP decoder with image.Decode
+)
+
+// ImageVariantSpec describes one size variant produced for every uploaded image
+type ImageVariantSpec struct {
+	Name    string
+	MaxSize int // longest edge in pixels
+}
+
+// ImageVariants lists the size variants produced for uploaded images, smallest first
+var ImageVariants = []ImageVariantSpec{
+	{Name: "thumbnail", MaxSize: 320},
+	{Name: "medium", MaxSize: 1080},
+	{Name: "original", MaxSize: 2048},
+}
+
+// CropVariantSpec describes one fixed size variant produced from a cropped image
+type CropVariantSpec struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// AvatarVariants lists the square sizes produced for avatars, smallest first
+var AvatarVariants = []CropVariantSpec{
+	{Name: "small", Width: 64, Height: 64},
+	{Name: "medium", Width: 200, Height: 200},
+	{Name: "large", Width: 400, Height: 400},
+}
+
+// CoverVariants lists the 3:1 banner sizes produced for cover images, smallest first
+var CoverVariants = []CropVariantSpec{
+	{Name: "medium", Width: 750, Height: 250},
+	{Name: "large", Width: 1500, Height: 500},
+}
+
+const jpegQuality = 85
+
+var (
+	// ErrUnsupportedImage is returned when uploaded bytes cannot be decoded as an image
+	ErrUnsupportedImage = errors.New("unsupported or corrupt image")
+	// ErrInvalidCrop is returned when a crop rectangle does not lie within the image
+	ErrInvalidCrop = errors.New("crop rectangle is outside the image")
+)
+
+// ProcessedImage is a re-encoded image variant ready to be stored
+type ProcessedImage struct {
+	Name        string
+	Data        []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	// Aliases names the smaller variants that would be identical to this one because
+	// the source already fits them; they are served by this variant instead
+	Aliases []string
+}
+
+// ProcessImage decodes an uploaded image, applies its EXIF orientation and re-encodes it
+// into the configured size variants. Re-encoding drops all metadata, including GPS EXIF
+// tags. Animated GIFs stay animated in every variant but the thumbnail, which shows the
+// first frame.
+func ProcessImage(data []byte) ([]ProcessedImage, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	if format == "gif" {
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedImage
+		}
+		if len(anim.Image) > 1 {
+			return processAnimatedGIF(anim)
+		}
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	// Photos stay JPEG; anything that may carry transparency or sharp edges stays PNG
+	asJPEG := format == "jpeg" || (format == "webp" && isOpaque(img))
+
+	return distinctVariants(ImageVariants, img.Bounds(), func(spec ImageVariantSpec) (ProcessedImage, error) {
+		return encodeVariant(spec, fit(img, spec.MaxSize), asJPEG)
+	})
+}
+
+// distinctVariants encodes each of the given size variants of an image with the given
+// bounds. Variants the image already fits would only repeat the next larger one, so they
+// are listed as its aliases instead of being encoded again.
+func distinctVariants(specs []ImageVariantSpec, bounds image.Rectangle, encode func(ImageVariantSpec) (ProcessedImage, error)) ([]ProcessedImage, error) {
+	longest := max(bounds.Dx(), bounds.Dy())
+
+	variants := make([]ProcessedImage, 0, len(specs))
+	var aliases []string
+	for i, spec := range specs {
+		if i < len(specs)-1 && longest <= spec.MaxSize {
+			aliases = append(aliases, spec.Name)
+			continue
+		}
+
+		variant, err := encode(spec)
+		if err != nil {
+			return nil, err
+		}
+		variant.Aliases = aliases
+		aliases = nil
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+// CropImage cuts a rectangle out of an image and scales it to each of the given fixed
+// size variants. A rectangle whose aspect ratio differs from a variant is trimmed around
+// its centre to fit. Animated GIFs are reduced to their first frame.
+func CropImage(data []byte, rect image.Rectangle, specs []CropVariantSpec) ([]ProcessedImage, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	if rect.Empty() || !rect.In(img.Bounds()) {
+		return nil, ErrInvalidCrop
+	}
+
+	cropped := imaging.Crop(img, rect)
+	asJPEG := format == "jpeg" || isOpaque(cropped)
+
+	variants := make([]ProcessedImage, 0, len(specs))
+	for _, spec := range specs {
+		resized := imaging.Fill(cropped, spec.Width, spec.Height, imaging.Center, imaging.Lanczos)
+		variant, err := encodeVariant(ImageVariantSpec{Name: spec.Name}, resized, asJPEG)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+// processAnimatedGIF re-encodes an animated GIF without its extension blocks into the
+// larger size variants and adds a static thumbnail of the first frame
+func processAnimatedGIF(anim *gif.GIF) ([]ProcessedImage, error) {
+	thumbSpec := ImageVariants[0]
+	thumb, err := encodeVariant(thumbSpec, fit(anim.Image[0], thumbSpec.MaxSize), false)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	animated, err := distinctVariants(ImageVariants[1:], bounds, func(spec ImageVariantSpec) (ProcessedImage, error) {
+		resized := fitAnimation(anim, spec.MaxSize)
+
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, resized); err != nil {
+			return ProcessedImage{}, err
+		}
+		return ProcessedImage{
+			Name:        spec.Name,
+			Data:        buf.Bytes(),
+			ContentType: "image/gif",
+			Ext:         ".gif",
+			Width:       resized.Config.Width,
+			Height:      resized.Config.Height,
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]ProcessedImage{thumb}, animated...), nil
+}
+
+// fitAnimation downscales an animated GIF so that its longest edge is at most maxSize.
+// Frames may only update part of the canvas, so each one is composited over the frames
+// before it and stored as a full frame.
+func fitAnimation(anim *gif.GIF, maxSize int) *gif.GIF {
+	width, height := anim.Config.Width, anim.Config.Height
+	if width <= maxSize && height <= maxSize {
+		return anim
+	}
+
+	size := imaging.Fit(image.NewNRGBA(image.Rect(0, 0, width, height)), maxSize, maxSize, imaging.NearestNeighbor).Bounds()
+	resized := &gif.GIF{
+		Image:     make([]*image.Paletted, len(anim.Image)),
+		Delay:     anim.Delay,
+		Disposal:  make([]byte, len(anim.Image)),
+		LoopCount: anim.LoopCount,
+		Config:    image.Config{Width: size.Dx(), Height: size.Dy()},
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i, frame := range anim.Image {
+		var previous *image.NRGBA
+		disposal := byte(0)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = imaging.Clone(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		scaled := imaging.Resize(canvas, size.Dx(), size.Dy(), imaging.Lanczos)
+		paletted := image.NewPaletted(scaled.Bounds(), frame.Palette)
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), scaled, image.Point{})
+		resized.Image[i] = paletted
+		// Every frame is complete, so the canvas is cleared before the next one is drawn
+		resized.Disposal[i] = gif.DisposalBackground
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return resized
+}
+
+// fit downscales an image so that its longest edge is at most maxSize
+func fit(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= maxSize && bounds.Dy() <= maxSize {
+		return img
+	}
+	return imaging.Fit(img, maxSize, maxSize, imaging.Lanczos)
+}
+
+// encodeVariant encodes a resized image as JPEG or PNG
+func encodeVariant(spec ImageVariantSpec, img image.Image, asJPEG bool) (ProcessedImage, error) {
+	var buf bytes.Buffer
+	variant := ProcessedImage{
+		Name:   spec.Name,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+
+	if asJPEG {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return variant, err
+		}
+		variant.ContentType = "image/jpeg"
+		variant.Ext = ".jpg"
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return variant, err
+		}
+		variant.ContentType = "image/png"
+		variant.Ext = ".png"
+	}
+
+	variant.Data = buf.Bytes()
+	return variant, nil
+}
+
+// isOpaque reports whether an image has no transparent pixels
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+// mentionPattern matches @username mentions that aren't part of an email address
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.]*\w)`)
+
+// ExtractMentions returns the lowercased usernames mentioned in text, without duplicates
+func ExtractMentions(text string) []string {
+	matches := mentionPattern.FindAllStringSubmatch(text, -1)
+	seen := make(map[string]bool, len(matches))
+	mentions := make([]string, 0, len(matches))
+	for _, match := range matches {
+		username := strings.ToLower(match[1])
+		if !seen[username] {
+			seen[username] = true
+			mentions = append(mentions, username)
+		}
+	}
+	return mentions
+}
+
+// IsMentioned reports whether username is mentioned in text
+func IsMentioned(text, username string) bool {
+	for _, mention := range ExtractMentions(text) {
+		if mention == strings.ToLower(username) {
+			return true
+		}
+	}
+	return false
+}
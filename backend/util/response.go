@@ -1,6 +1,7 @@
 package util
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
@@ -57,6 +58,16 @@ func RespondWithPagination(c *gin.Context, statusCode int, message string, data
 	})
 }
 
+// RespondWithListError sends the error of a failed list query. A cursor that can't be
+// decoded is the client's fault; anything else is the server's.
+func RespondWithListError(c *gin.Context, err error, errorMessage string) {
+	if errors.Is(err, ErrInvalidCursor) {
+		RespondWithError(c, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	RespondWithError(c, http.StatusInternalServerError, errorMessage, err)
+}
+
 // RespondWithNoContent sends a 204 No Content response
 func RespondWithNoContent(c *gin.Context) {
 	c.Status(http.StatusNoContent)
@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"socialnet/model"
+
+	"github.com/google/uuid"
+)
+
+// maxCoalescedAuthors caps the author IDs listed in one new_post event
+const maxCoalescedAuthors = 10
+
+// Coalescer merges the new posts meant for a user into a single new_post event sent at
+// most once per interval, so that a burst of posts by followed accounts reaches each
+// follower as one small update
+type Coalescer struct {
+	hub      *Hub
+	interval time.Duration
+	pending  map[uuid.UUID]*model.NewPostEvent
+	mutex    sync.Mutex
+}
+
+// NewCoalescer creates a new Coalescer
+func NewCoalescer(hub *Hub, interval time.Duration) *Coalescer {
+	return &Coalescer{
+		hub:      hub,
+		interval: interval,
+		pending:  make(map[uuid.UUID]*model.NewPostEvent),
+	}
+}
+
+// Add queues a new post by authorID for a user's next new_post event
+func (c *Coalescer) Add(userID, authorID, postID uuid.UUID) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	event, ok := c.pending[userID]
+	if !ok {
+		event = &model.NewPostEvent{}
+		c.pending[userID] = event
+	}
+
+	event.Count++
+	event.LatestPostID = postID
+	for _, id := range event.AuthorIDs {
+		if id == authorID {
+			return
+		}
+	}
+	if len(event.AuthorIDs) < maxCoalescedAuthors {
+		event.AuthorIDs = append(event.AuthorIDs, authorID)
+	}
+}
+
+// Run sends the queued events on every interval until the context is cancelled
+func (c *Coalescer) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.flush()
+		}
+	}
+}
+
+// flush sends every queued event. Users who went offline meanwhile are skipped; they
+// can ask for the new post count when they reconnect.
+func (c *Coalescer) flush() {
+	c.mutex.Lock()
+	pending := c.pending
+	c.pending = make(map[uuid.UUID]*model.NewPostEvent)
+	c.mutex.Unlock()
+
+	for userID, event := range pending {
+		message, _ := json.Marshal(model.NewWsMessage(userID, model.WsMessageTypeNewPost, event))
+		c.hub.SendToUser(userID, message)
+	}
+}
@@ -94,6 +94,18 @@ func (h *Hub) SendToUser(userID uuid.UUID, message []byte) {
 	}
 }
 
+// OnlineUsers returns the IDs of the users with at least one open connection
+func (h *Hub) OnlineUsers() []uuid.UUID {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	userIDs := make([]uuid.UUID, 0, len(h.userConns))
+	for userID := range h.userConns {
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs
+}
+
 func (h *Hub) removeUserConnection(client *Client) {
 	if conns, exists := h.userConns[client.UserID]; exists {
 		var newConns []*Client
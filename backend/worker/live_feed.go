@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"socialnet/config"
+	"socialnet/model"
+	"socialnet/repository"
+	"socialnet/websocket"
+
+	"github.com/google/uuid"
+)
+
+// liveFeedRetryDelay is how long LiveFeed waits before listening again after its
+// database connection failed
+const liveFeedRetryDelay = 5 * time.Second
+
+// LiveFeed tells the followers connected to this instance about posts fanned out by any
+// instance. Every server runs one next to its websocket hub, so live updates reach
+// followers whichever instance they are connected to.
+type LiveFeed struct {
+	repo *repository.Repository
+	hub  *websocket.Hub
+	live *websocket.Coalescer
+}
+
+// NewLiveFeed creates a new LiveFeed
+func NewLiveFeed(repo *repository.Repository, hub *websocket.Hub, cfg *config.Config) *LiveFeed {
+	return &LiveFeed{
+		repo: repo,
+		hub:  hub,
+		live: websocket.NewCoalescer(hub, cfg.Timeline.LiveUpdateInterval),
+	}
+}
+
+// Run listens for fanned out posts until the context is cancelled, listening again
+// whenever the connection fails
+func (l *LiveFeed) Run(ctx context.Context) {
+	go l.live.Run(ctx)
+
+	for {
+		err := l.repo.Timeline.ListenNewPosts(ctx, l.notify)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Listening for new posts failed: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(liveFeedRetryDelay):
+		}
+	}
+}
+
+// notify queues new_post events for the followers of the posts' authors who are
+// connected to this instance
+func (l *LiveFeed) notify(notices []model.NewPostNotice) {
+	online := l.hub.OnlineUsers()
+	if len(online) == 0 || len(notices) == 0 {
+		return
+	}
+
+	authorIDs := make([]uuid.UUID, 0, len(notices))
+	for _, notice := range notices {
+		authorIDs = append(authorIDs, notice.AuthorID)
+	}
+	follows, err := l.repo.Timeline.FindFollowers(authorIDs, online)
+	if err != nil {
+		log.Printf("Sending live feed updates failed: %v", err)
+		return
+	}
+
+	followers := make(map[uuid.UUID][]uuid.UUID)
+	for _, follow := range follows {
+		followers[follow.FollowingID] = append(followers[follow.FollowingID], follow.FollowerID)
+	}
+	for _, notice := range notices {
+		for _, followerID := range followers[notice.AuthorID] {
+			l.live.Add(followerID, notice.AuthorID, notice.PostID)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"socialnet/config"
+	"socialnet/repository"
+)
+
+// pollBatchSize is the number of expired polls closed per transaction
+const pollBatchSize = 50
+
+// PollCloser closes polls once they expire and tells their post owners. Any number of
+// closers may run against the same database.
+type PollCloser struct {
+	repo     *repository.Repository
+	interval time.Duration
+}
+
+// NewPollCloser creates a new PollCloser
+func NewPollCloser(repo *repository.Repository, cfg *config.Config) *PollCloser {
+	return &PollCloser{
+		repo:     repo,
+		interval: cfg.Scheduler.Interval,
+	}
+}
+
+// Run closes expired polls on every interval until the context is cancelled
+func (p *PollCloser) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			closed, err := p.CloseExpired(ctx)
+			if err != nil {
+				log.Printf("Closing expired polls failed: %v", err)
+			}
+			if closed > 0 {
+				log.Printf("Closed %d polls", closed)
+			}
+		}
+	}
+}
+
+// CloseExpired closes every expired poll, notifies the owners of their posts and
+// returns how many polls were closed
+func (p *PollCloser) CloseExpired(ctx context.Context) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		polls, err := p.repo.Poll.CloseExpired(time.Now(), pollBatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		for _, poll := range polls {
+			// Deleted posts have nobody left to tell
+			post, err := p.repo.Post.FindByID(poll.PostID)
+			if err != nil {
+				continue
+			}
+			if err := p.repo.Notification.CreatePollClosedNotification(post.UserID, post.ID); err != nil {
+				log.Printf("Failed to notify owner of post %s about its closed poll: %v", post.ID, err)
+			}
+		}
+
+		total += len(polls)
+		if len(polls) < pollBatchSize {
+			return total, nil
+		}
+	}
+	return total, ctx.Err()
+}
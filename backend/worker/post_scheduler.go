@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"socialnet/config"
+	"socialnet/repository"
+)
+
+// scheduleBatchSize is the number of due posts published per transaction
+const scheduleBatchSize = 50
+
+// PostScheduler publishes scheduled posts once their publish time has passed. Any
+// number of schedulers may run against the same database.
+type PostScheduler struct {
+	repo     *repository.Repository
+	interval time.Duration
+}
+
+// NewPostScheduler creates a new PostScheduler
+func NewPostScheduler(repo *repository.Repository, cfg *config.Config) *PostScheduler {
+	return &PostScheduler{
+		repo:     repo,
+		interval: cfg.Scheduler.Interval,
+	}
+}
+
+// Run publishes due posts on every interval until the context is cancelled
+func (s *PostScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			published, err := s.PublishDue(ctx)
+			if err != nil {
+				log.Printf("Publishing scheduled posts failed: %v", err)
+			}
+			if published > 0 {
+				log.Printf("Published %d scheduled posts", published)
+			}
+		}
+	}
+}
+
+// PublishDue publishes every post that is due and returns how many were published
+func (s *PostScheduler) PublishDue(ctx context.Context) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		posts, err := s.repo.Post.PublishDue(time.Now(), scheduleBatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += len(posts)
+		if len(posts) < scheduleBatchSize {
+			return total, nil
+		}
+	}
+	return total, ctx.Err()
+}
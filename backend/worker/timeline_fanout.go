@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"socialnet/config"
+	"socialnet/repository"
+)
+
+// fanoutBatchSize is the number of queued posts fanned out per transaction
+const fanoutBatchSize = 100
+
+// TimelineFanout copies newly published posts to the home timelines of their authors'
+// followers and announces them to every instance's LiveFeed. Any number of workers may
+// run against the same database.
+type TimelineFanout struct {
+	repo          *repository.Repository
+	interval      time.Duration
+	pullThreshold int
+	backfillSize  int
+}
+
+// NewTimelineFanout creates a new TimelineFanout
+func NewTimelineFanout(repo *repository.Repository, cfg *config.Config) *TimelineFanout {
+	return &TimelineFanout{
+		repo:          repo,
+		interval:      cfg.Timeline.FanoutInterval,
+		pullThreshold: cfg.Timeline.PullThreshold,
+		backfillSize:  cfg.Timeline.BackfillSize,
+	}
+}
+
+// Run fans out queued posts on every interval until the context is cancelled
+func (f *TimelineFanout) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := f.FanOutQueued(ctx); err != nil {
+				log.Printf("Timeline fan-out failed: %v", err)
+			}
+		}
+	}
+}
+
+// FanOutQueued fans out every queued post and returns how many were fanned out
+func (f *TimelineFanout) FanOutQueued(ctx context.Context) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		done, err := f.repo.Timeline.FanOut(fanoutBatchSize, f.pullThreshold)
+		total += done
+		if err != nil {
+			return total, err
+		}
+		if done < fanoutBatchSize {
+			return total, nil
+		}
+	}
+	return total, ctx.Err()
+}
+
+// Rebuild rebuilds every home timeline from the follow graph, then fans out the posts
+// queued meanwhile
+func (f *TimelineFanout) Rebuild(ctx context.Context) (int, error) {
+	rebuilt, err := f.repo.Timeline.Rebuild(f.backfillSize, f.pullThreshold)
+	if err != nil {
+		return rebuilt, err
+	}
+	_, err = f.FanOutQueued(ctx)
+	return rebuilt, err
+}
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"socialnet/config"
+	"socialnet/model"
+	"socialnet/repository"
+)
+
+// TrendingRefresher periodically recomputes the cached trending ranking of every window
+// and drops engagement events that no window covers anymore
+type TrendingRefresher struct {
+	repo     *repository.Repository
+	interval time.Duration
+	size     int
+	gravity  float64
+}
+
+// NewTrendingRefresher creates a new TrendingRefresher
+func NewTrendingRefresher(repo *repository.Repository, cfg *config.Config) *TrendingRefresher {
+	return &TrendingRefresher{
+		repo:     repo,
+		interval: cfg.Trending.RefreshInterval,
+		size:     cfg.Trending.Size,
+		gravity:  cfg.Trending.Gravity,
+	}
+}
+
+// Run refreshes the rankings right away and then on every interval until the context
+// is cancelled
+func (t *TrendingRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Refresh(ctx); err != nil {
+			log.Printf("Refreshing trending posts failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh recomputes the ranking of every window, then prunes expired events
+func (t *TrendingRefresher) Refresh(ctx context.Context) error {
+	now := time.Now()
+	longest := time.Duration(0)
+	for window, length := range model.TrendingWindows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := t.repo.Trending.Refresh(window, now, t.size, t.gravity); err != nil {
+			return err
+		}
+		longest = max(longest, length)
+	}
+
+	pruned, err := t.repo.Trending.PruneEvents(now.Add(-longest))
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		log.Printf("Pruned %d engagement events", pruned)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"socialnet/config"
+	"socialnet/repository"
+	"socialnet/storage"
+)
+
+// gcBatchSize is the number of orphaned uploads removed per query
+const gcBatchSize = 100
+
+// UploadGC periodically deletes uploads that nothing references, such as files that
+// were never attached to a post or whose post, avatar or cover was replaced or deleted
+type UploadGC struct {
+	repo     *repository.Repository
+	store    storage.Storage
+	interval time.Duration
+	maxAge   time.Duration
+}
+
+// NewUploadGC creates a new UploadGC
+func NewUploadGC(repo *repository.Repository, store storage.Storage, cfg *config.Config) *UploadGC {
+	return &UploadGC{
+		repo:     repo,
+		store:    store,
+		interval: cfg.Storage.GCInterval,
+		maxAge:   cfg.Storage.OrphanMaxAge,
+	}
+}
+
+// Run collects orphaned uploads on every interval until the context is cancelled
+func (gc *UploadGC) Run(ctx context.Context) {
+	ticker := time.NewTicker(gc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := gc.Collect(ctx)
+			if err != nil {
+				log.Printf("Upload GC failed: %v", err)
+			}
+			if deleted > 0 {
+				log.Printf("Upload GC deleted %d orphaned uploads", deleted)
+			}
+		}
+	}
+}
+
+// Collect deletes every upload that has been unreferenced for longer than the maximum
+// age and returns how many were deleted
+func (gc *UploadGC) Collect(ctx context.Context) (int, error) {
+	before := time.Now().Add(-gc.maxAge)
+	deleted := 0
+
+	for {
+		uploads, err := gc.repo.Upload.FindUnreferenced(before, gcBatchSize)
+		if err != nil {
+			return deleted, err
+		}
+
+		for _, upload := range uploads {
+			// The record is removed first so that an upload referenced in the meantime
+			// is never deleted from storage
+			ok, err := gc.repo.Upload.DeleteIfUnreferenced(upload.ID, before)
+			if err != nil {
+				return deleted, err
+			}
+			if !ok {
+				continue
+			}
+
+			for _, key := range upload.Keys() {
+				if err := gc.store.Delete(ctx, key); err != nil {
+					log.Printf("Upload GC failed to delete object %s: %v", key, err)
+				}
+			}
+			deleted++
+		}
+
+		if len(uploads) < gcBatchSize || ctx.Err() != nil {
+			return deleted, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"socialnet/config"
+	"socialnet/model"
+	"socialnet/repository"
+	"socialnet/scanner"
+	"socialnet/storage"
+	"socialnet/transcoder"
+)
+
+// errInfected is returned for uploads rejected by the malware scanner
+var errInfected = errors.New("video was rejected by the malware scanner")
+
+// staleTranscodeAfter is how long an upload may stay claimed before another worker
+// assumes the one processing it has died
+const staleTranscodeAfter = time.Hour
+
+// VideoProcessor transcodes uploaded videos queued for processing. Any number of
+// processors may run against the same database.
+type VideoProcessor struct {
+	repo       *repository.Repository
+	store      storage.Storage
+	transcoder transcoder.Transcoder
+	scanner    scanner.Scanner
+	workDir    string
+	interval   time.Duration
+}
+
+// NewVideoProcessor creates a new VideoProcessor. The scanner may be nil to disable
+// malware scanning.
+func NewVideoProcessor(repo *repository.Repository, store storage.Storage, tc transcoder.Transcoder, fileScanner scanner.Scanner, cfg *config.Config) *VideoProcessor {
+	return &VideoProcessor{
+		repo:       repo,
+		store:      store,
+		transcoder: tc,
+		scanner:    fileScanner,
+		workDir:    cfg.Video.WorkDir,
+		interval:   cfg.Video.PollInterval,
+	}
+}
+
+// Run polls the processing queue on every interval until the context is cancelled
+func (p *VideoProcessor) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Drain(ctx); err != nil {
+				log.Printf("Video processing failed: %v", err)
+			}
+		}
+	}
+}
+
+// Drain processes queued uploads until the queue is empty
+func (p *VideoProcessor) Drain(ctx context.Context) error {
+	for ctx.Err() == nil {
+		upload, err := p.repo.Upload.ClaimProcessing(time.Now().Add(-staleTranscodeAfter))
+		if err != nil {
+			return err
+		}
+		if upload == nil {
+			return nil
+		}
+
+		if err := p.process(ctx, upload); err != nil {
+			log.Printf("Failed to process video upload %s: %v", upload.ID, err)
+			if err := p.repo.Upload.FailProcessing(upload, p.store.URL(upload.Key), failureReason(err)); err != nil {
+				return err
+			}
+		}
+
+		// The chunks are no longer needed whether or not the video could be processed
+		for _, key := range upload.ChunkKeys() {
+			if err := p.store.Delete(ctx, key); err != nil {
+				log.Printf("Failed to delete upload chunk %s: %v", key, err)
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// process assembles the chunks of an upload, transcodes them and stores the rendition
+// under the upload's key and the poster frame next to it
+func (p *VideoProcessor) process(ctx context.Context, upload *model.Upload) error {
+	dir, err := os.MkdirTemp(p.workDir, "video-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "source")
+	if err := p.assemble(ctx, upload, source); err != nil {
+		return err
+	}
+
+	// The chunks stay private until the assembled file is known to be clean
+	if err := p.scan(ctx, upload, source); err != nil {
+		return err
+	}
+
+	result, err := p.transcoder.Transcode(ctx, source, dir)
+	if err != nil {
+		return err
+	}
+
+	posterKey := strings.TrimSuffix(upload.Key, filepath.Ext(upload.Key)) + "-poster.jpg"
+
+	videoSize, err := putFile(ctx, p.store, upload.Key, result.VideoPath, "video/mp4")
+	if err != nil {
+		return err
+	}
+	posterSize, err := putFile(ctx, p.store, posterKey, result.PosterPath, "image/jpeg")
+	if err != nil {
+		return err
+	}
+
+	duration := result.Duration.Seconds()
+	upload.Size = videoSize + posterSize
+	upload.Variants = map[string]string{"poster": posterKey}
+	upload.Width = &result.Width
+	upload.Height = &result.Height
+	upload.Duration = &duration
+
+	posterURL := p.store.URL(posterKey)
+	return p.repo.Upload.FinishProcessing(upload, p.store.URL(upload.Key), &posterURL)
+}
+
+// assemble concatenates the stored chunks of an upload into a local file
+func (p *VideoProcessor) assemble(ctx context.Context, upload *model.Upload, target string) error {
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for offset := int64(0); offset < upload.Received; offset += upload.ChunkSize {
+		length := min(upload.ChunkSize, upload.Received-offset)
+		body, err := p.store.GetRange(ctx, upload.ChunkKey(offset), 0, length)
+		if err != nil {
+			return fmt.Errorf("failed to read chunk at offset %d: %w", offset, err)
+		}
+
+		_, err = io.Copy(f, body)
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+// scan runs the malware scanner over the assembled source of an upload
+func (p *VideoProcessor) scan(ctx context.Context, upload *model.Upload, path string) error {
+	if p.scanner == nil {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	result, err := p.scanner.Scan(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	if !result.Clean {
+		log.Printf("Rejected infected video upload %s from user %s: %s", upload.ID, upload.UserID, result.Signature)
+		return errInfected
+	}
+	return nil
+}
+
+// putFile uploads a local file to storage and returns its size
+func putFile(ctx context.Context, store storage.Storage, key, path, contentType string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), store.Put(ctx, key, f, info.Size(), contentType)
+}
+
+// failureReason returns the message shown to the uploader for a processing error
+func failureReason(err error) string {
+	if errors.Is(err, transcoder.ErrUnreadableVideo) || errors.Is(err, transcoder.ErrVideoTooLong) || errors.Is(err, errInfected) {
+		return err.Error()
+	}
+	return "video could not be processed"
+}